@@ -0,0 +1,87 @@
+package yar
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// BindParams sets the fields of the struct dst points to from the variables
+// captured for r, going by their param tag, as in
+//
+//	var p struct {
+//		ID int `param:"id"`
+//	}
+//	err := yar.BindParams(r, &p)
+//
+// for /users/<id>. Fields can be strings, ints, uints, floats or bools.
+// Fields whose variable wasn't captured are left as they are. It returns an
+// error if a value can't be converted to its field.
+func BindParams(r *http.Request, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("BindParams needs a pointer to a struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	p := getParams(r)
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := rt.Field(i).Tag.Lookup("param")
+		if !ok || p == nil {
+			continue
+		}
+		value, found := "", false
+		for j, n := range p.names {
+			if n == name {
+				value, found = p.values[j], true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		if err := setField(rv.Field(i), value); err != nil {
+			return errors.New("Param " + name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// setField converts value to the type of field and sets it
+func setField(field reflect.Value, value string) error {
+	if !field.CanSet() {
+		return errors.New("field is not exported")
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return errors.New("unsupported field type " + field.Type().String())
+	}
+	return nil
+}
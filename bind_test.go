@@ -0,0 +1,35 @@
+package yar
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBindParams(t *testing.T) {
+	type user struct {
+		ID    int     `param:"id"`
+		Name  string  `param:"name"`
+		Admin bool    `param:"admin"`
+		Score float64 `param:"score"`
+		Other string
+	}
+	rtr := NewRouter()
+	var got user
+	var err error
+	rtr.HandleFunc("/users/<id>/<name>/<admin>$", func(w http.ResponseWriter, r *http.Request) {
+		got = user{Score: 1.5, Other: "keep"}
+		err = BindParams(r, &got)
+	})
+	serve(rtr, "GET", "/users/42/bob/true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (user{42, "bob", true, 1.5, "keep"}); got != want {
+		t.Errorf("bound %+v, want %+v", got, want)
+	}
+	serve(rtr, "GET", "/users/x/bob/true")
+	if err == nil || !strings.Contains(err.Error(), "Param id") {
+		t.Errorf("bad int: error %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package yar
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerOptions configure a CircuitBreaker
+type BreakerOptions struct {
+	// consecutive 5xx responses that trip the breaker, defaults to 5
+	Threshold int
+	// how long the breaker stays open before letting a trial request
+	// through, defaults to 10s
+	Cooldown time.Duration
+}
+
+// breaker states
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker returns middleware that fails fast with a 503 once the
+// handlers it wraps have replied with a 5xx status Threshold times in a
+// row, so a failing dependency isn't hammered. After Cooldown one request
+// is let through: if it succeeds the breaker closes again, otherwise it
+// stays open for another Cooldown. Requests that were already running when
+// the breaker tripped don't change it when they finish, only the trial
+// request can close it. The state is shared by every route the
+// returned middleware wraps, use one per route to track them separately.
+func CircuitBreaker(opts BreakerOptions) Middleware {
+	if opts.Threshold <= 0 {
+		opts.Threshold = 5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 10 * time.Second
+	}
+	var mu sync.Mutex
+	state, failures := breakerClosed, 0
+	var openedAt time.Time
+	// generation counts the state changes, a request only moves the state
+	// if it hasn't changed since the request was let through, so a slow
+	// request admitted while closed can't close a breaker that has tripped
+	generation := 0
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			if state == breakerHalfOpen || (state == breakerOpen && time.Since(openedAt) < opts.Cooldown) {
+				// open, or a trial request is already running
+				retry := opts.Cooldown - time.Since(openedAt)
+				mu.Unlock()
+				ServiceUnavailable(w, retry)
+				return
+			}
+			if state == breakerOpen {
+				state = breakerHalfOpen
+				generation++
+			}
+			admitted := generation
+			mu.Unlock()
+
+			sw := &statusWriter{ResponseWriter: w}
+			ok := false
+			defer func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if admitted != generation {
+					return
+				}
+				if ok && sw.status < 500 {
+					if state != breakerClosed {
+						generation++
+					}
+					state, failures = breakerClosed, 0
+					return
+				}
+				failures++
+				if state == breakerHalfOpen || failures >= opts.Threshold {
+					state, openedAt = breakerOpen, time.Now()
+					generation++
+				}
+			}()
+			f(sw, r)
+			ok = true
+		}
+	}
+}
+
+// statusWriter records the status code written
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,73 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	rtr := NewRouter()
+	fail := true
+	calls := 0
+	rtr.Use(CircuitBreaker(BreakerOptions{Threshold: 2, Cooldown: 50 * time.Millisecond}))
+	rtr.HandleFunc("/dep", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	expect := func(step string, code, wantCalls int) {
+		t.Helper()
+		if w := serve(rtr, "GET", "/dep"); w.Code != code || calls != wantCalls {
+			t.Fatalf("%s: %d after %d calls, want %d after %d", step, w.Code, calls, code, wantCalls)
+		}
+	}
+	expect("first failure", http.StatusBadGateway, 1)
+	expect("second failure", http.StatusBadGateway, 2)
+	if w := serve(rtr, "GET", "/dep"); w.Code != http.StatusServiceUnavailable || calls != 2 || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("open breaker = %d after %d calls, Retry-After %q", w.Code, calls, w.Header().Get("Retry-After"))
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	expect("failed trial", http.StatusBadGateway, 3)
+	expect("open again", http.StatusServiceUnavailable, 3)
+
+	time.Sleep(60 * time.Millisecond)
+	fail = false
+	expect("successful trial", http.StatusOK, 4)
+	expect("closed", http.StatusOK, 5)
+}
+
+func TestCircuitBreakerSlowRequest(t *testing.T) {
+	rtr := NewRouter()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	rtr.Use(CircuitBreaker(BreakerOptions{Threshold: 1, Cooldown: time.Hour}))
+	rtr.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("ok"))
+	})
+	rtr.HandleFunc("/dep", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	done := make(chan int)
+	go func() { done <- serve(rtr, "GET", "/slow").Code }()
+	<-started
+	if w := serve(rtr, "GET", "/dep"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failure = %d", w.Code)
+	}
+	if w := serve(rtr, "GET", "/dep"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("tripped breaker = %d, want 503", w.Code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("slow request = %d", code)
+	}
+	if w := serve(rtr, "GET", "/dep"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("after a slow request admitted while closed succeeded = %d, want the breaker still open", w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package yar
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Coalesce returns middleware that collapses concurrent GET and HEAD
+// requests with the same key, so the handler runs once and the requests
+// that arrive while it runs get a copy of its response. keyFn returns the
+// key of a request, e.g. its method and URL, or "" to handle it on its own.
+// Use it for expensive responses that are the same for everyone with the
+// key, as the response is buffered to replay it. Other methods are never
+// coalesced.
+func Coalesce(keyFn func(*http.Request) string) Middleware {
+	var mu sync.Mutex
+	calls := map[string]*coalescedCall{}
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				f(w, r)
+				return
+			}
+			key := keyFn(r)
+			if key == "" {
+				f(w, r)
+				return
+			}
+			key = r.Method + " " + key
+			mu.Lock()
+			if c, ok := calls[key]; ok {
+				mu.Unlock()
+				<-c.done
+				if c.ok {
+					c.response.replay(w)
+				} else {
+					f(w, r)
+				}
+				return
+			}
+			c := &coalescedCall{done: make(chan struct{})}
+			calls[key] = c
+			mu.Unlock()
+
+			rec := &recordingWriter{ResponseWriter: w}
+			defer func() {
+				mu.Lock()
+				delete(calls, key)
+				mu.Unlock()
+				close(c.done)
+			}()
+			f(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			c.response = storedResponse{
+				status: rec.status,
+				header: rec.Header().Clone(),
+				body:   rec.body.Bytes(),
+			}
+			c.ok = true
+		}
+	}
+}
+
+// coalescedCall is a request whose response others are waiting for
+type coalescedCall struct {
+	done     chan struct{}
+	ok       bool // false if the handler panicked
+	response storedResponse
+}
@@ -0,0 +1,52 @@
+package yar
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	rtr := NewRouter()
+	var runs atomic.Int32
+	release := make(chan struct{})
+	rtr.Use(Coalesce(func(r *http.Request) string { return r.URL.String() }))
+	rtr.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		runs.Add(1)
+		<-release
+		w.Header().Set("X-Run", "1")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("data"))
+	})
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := serve(rtr, "GET", "/slow")
+			codes[i], bodies[i] = w.Code, w.Body.String()
+		}(i)
+	}
+	// give the requests time to join the first one
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("handler ran %d times, want 1", n)
+	}
+	for i := range bodies {
+		if codes[i] != http.StatusAccepted || bodies[i] != "data" {
+			t.Errorf("request %d got %d %q", i, codes[i], bodies[i])
+		}
+	}
+
+	serve(rtr, "GET", "/slow")
+	serve(rtr, "POST", "/slow")
+	if n := runs.Load(); n != 3 {
+		t.Errorf("handler ran %d times after a later GET and a POST, want 3", n)
+	}
+}
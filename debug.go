@@ -0,0 +1,41 @@
+package yar
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// EnableDebug registers prefix+"/routes", which serves ListRoutes as JSON,
+// and prefix+"/vars", which serves basic runtime stats. It is meant for
+// development and nothing is registered unless it is called.
+func (rtr *Router) EnableDebug(prefix string) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	started := time.Now()
+	if err := rtr.addFixedRoute(prefix+"/routes", func(w http.ResponseWriter, r *http.Request) {
+		writeDebugJSON(w, rtr.ListRoutes())
+	}); err != nil {
+		return err
+	}
+	return rtr.addFixedRoute(prefix+"/vars", func(w http.ResponseWriter, r *http.Request) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		writeDebugJSON(w, map[string]interface{}{
+			"go_version": runtime.Version(),
+			"goroutines": runtime.NumGoroutine(),
+			"heap_alloc": m.HeapAlloc,
+			"heap_sys":   m.HeapSys,
+			"num_gc":     m.NumGC,
+			"uptime_s":   int(time.Since(started) / time.Second),
+		})
+	})
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -0,0 +1,56 @@
+package yar
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestEnableDebug(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a", write(""))
+	rtr.HandleMethod("GET", "/u/<id>", write(""))
+	rtr.Host("x.com").HandleFunc("/h", write(""))
+	if err := rtr.EnableDebug("/debug/"); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(rtr, "GET", "/debug/routes")
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var routes []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &routes); err != nil {
+		t.Fatal(err)
+	}
+	want := []RouteInfo{
+		{Pattern: "/a", Kind: "fixed"},
+		{Pattern: "/debug/routes", Kind: "fixed"},
+		{Pattern: "/debug/vars", Kind: "fixed"},
+		{Pattern: "/u/([^/]+)", Declared: "/u/<id>", Kind: "params", Methods: []string{"GET"}},
+		{Pattern: "/h", Kind: "fixed", Host: "x.com"},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("routes = %+v, want %+v", routes, want)
+	}
+
+	var vars map[string]interface{}
+	if err := json.Unmarshal(serve(rtr, "GET", "/debug/vars").Body.Bytes(), &vars); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"go_version", "goroutines", "heap_alloc", "num_gc", "uptime_s"} {
+		if _, ok := vars[k]; !ok {
+			t.Errorf("vars has no %s", k)
+		}
+	}
+
+	if err := NewRouter().EnableDebug("/debug"); err != nil {
+		t.Errorf("EnableDebug without a trailing /: %v", err)
+	}
+	if err := rtr.EnableDebug("/debug"); err == nil {
+		t.Error("EnableDebug twice returned no error")
+	}
+	if w := serve(NewRouter(), "GET", "/debug/routes"); w.Code != http.StatusNotFound {
+		t.Errorf("debug routes without EnableDebug = %d", w.Code)
+	}
+}
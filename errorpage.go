@@ -0,0 +1,33 @@
+package yar
+
+import "net/http"
+
+// ErrorPage has f write the response whenever a handler sets status, e.g.
+// to render a styled 403 page. f gets the request the handler got, so Param
+// and PathRemainder work in it, and the status is the default for what it
+// writes. Whatever the handler writes
+// after setting the status is dropped. This only works if the handler calls
+// WriteHeader(status) (as http.Error does) before writing any of the body,
+// once bytes are written the status has been sent and can't be changed.
+// It also applies to the router's own responses, e.g. NotFound for 404.
+func (rtr *Router) ErrorPage(status int, f http.HandlerFunc) {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	if rtr.errorPages == nil {
+		rtr.errorPages = map[int]http.HandlerFunc{}
+	}
+	rtr.errorPages[status] = f
+}
+
+func (rtr *Router) hasErrorPages() bool {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	return len(rtr.errorPages) > 0
+}
+
+// errorPage returns the function registered for status or nil
+func (rtr *Router) errorPage(status int) http.HandlerFunc {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	return rtr.errorPages[status]
+}
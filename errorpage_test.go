@@ -0,0 +1,58 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestErrorPage(t *testing.T) {
+	rtr := NewRouter()
+	rtr.ErrorPage(http.StatusForbidden, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<h1>Forbidden " + r.URL.Path + "</h1>"))
+	})
+	rtr.ErrorPage(http.StatusNotFound, write("<h1>Not found</h1>"))
+	rtr.HandleFunc("/secret", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+		w.Write([]byte("more"))
+	})
+	rtr.HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("x"))
+		w.WriteHeader(http.StatusForbidden)
+	})
+	w := serve(rtr, "GET", "/secret")
+	if w.Code != http.StatusForbidden || w.Body.String() != "<h1>Forbidden /secret</h1>" {
+		t.Errorf("GET /secret = %d %q, want the error page", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html" {
+		t.Errorf("GET /secret: Content-Type %q, want the error page's", ct)
+	}
+	if w := serve(rtr, "GET", "/late"); w.Code != http.StatusOK || w.Body.String() != "x" {
+		t.Errorf("status after the body = %d %q, want it ignored", w.Code, w.Body)
+	}
+	if w := serve(rtr, "GET", "/missing"); w.Code != http.StatusNotFound || w.Body.String() != "<h1>Not found</h1>" {
+		t.Errorf("router's 404 = %d %q, want the error page", w.Code, w.Body)
+	}
+}
+
+func TestErrorPageRoutedRequest(t *testing.T) {
+	rtr := NewRouter()
+	rtr.ErrorPage(http.StatusForbidden, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("forbidden " + Param(r, "id") + PathRemainder(r)))
+	})
+	forbid := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusForbidden) }
+	rtr.HandleFunc("/u/<id>", forbid)
+	rtr.HandleSubtree("/files", forbid)
+	rtr.Use(func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { f(&statusWriter{ResponseWriter: w}, r) }
+	})
+	tests := map[string]string{
+		"/u/5":       "forbidden 5",
+		"/files/a/b": "forbidden /a/b",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+}
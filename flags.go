@@ -0,0 +1,60 @@
+package yar
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// HandleIf registers f for method and pattern only if enabled is set, it
+// does nothing otherwise. It is the way to register routes behind feature
+// flags that are decided at startup. An empty method registers f for all
+// methods, as HandleFunc does. Use HandleFlag for a flag that can change
+// while serving.
+func (rtr *Router) HandleIf(enabled bool, method, pattern string, f http.HandlerFunc) error {
+	if !enabled {
+		return nil
+	}
+	return rtr.handle(method, pattern, f)
+}
+
+// Flag is a feature flag that can be turned on and off while serving
+type Flag struct {
+	on atomic.Bool
+}
+
+// NewFlag returns a Flag that is on or off
+func NewFlag(on bool) *Flag {
+	flag := &Flag{}
+	flag.on.Store(on)
+	return flag
+}
+
+// Set turns the flag on or off
+func (flag *Flag) Set(on bool) {
+	flag.on.Store(on)
+}
+
+// On reports if the flag is on
+func (flag *Flag) On() bool {
+	return flag.on.Load()
+}
+
+// HandleFlag registers f for method and pattern, but while flag is off
+// requests to it get NotFound as if it wasn't registered
+func (rtr *Router) HandleFlag(flag *Flag, method, pattern string, f http.HandlerFunc) error {
+	return rtr.handle(method, pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !flag.On() {
+			rtr.notFound(w, r)
+			return
+		}
+		f(w, r)
+	})
+}
+
+// handle registers f with HandleMethod, or HandleFunc if method is ""
+func (rtr *Router) handle(method, pattern string, f http.HandlerFunc) error {
+	if method == "" {
+		return rtr.HandleFunc(pattern, f)
+	}
+	return rtr.HandleMethod(method, pattern, f)
+}
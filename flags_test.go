@@ -0,0 +1,42 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleIf(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleIf(false, "GET", "/off", write("off")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rtr.HandleIf(true, "GET", "/on", write("on")); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(rtr, "GET", "/off"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /off = %d, want 404", w.Code)
+	}
+	if got := serve(rtr, "GET", "/on").Body.String(); got != "on" {
+		t.Errorf("GET /on = %q", got)
+	}
+	if w := serve(rtr, "POST", "/on"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /on = %d, want 405", w.Code)
+	}
+}
+
+func TestHandleFlag(t *testing.T) {
+	rtr := NewRouter()
+	flag := NewFlag(false)
+	rtr.HandleFlag(flag, "", "/f", write("flag"))
+	if w := serve(rtr, "GET", "/f"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /f with the flag off = %d, want 404", w.Code)
+	}
+	flag.Set(true)
+	if got := serve(rtr, "GET", "/f").Body.String(); got != "flag" {
+		t.Errorf("GET /f with the flag on = %q", got)
+	}
+	flag.Set(false)
+	if w := serve(rtr, "GET", "/f"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /f with the flag off again = %d, want 404", w.Code)
+	}
+}
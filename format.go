@@ -0,0 +1,46 @@
+package yar
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FormatParam is the variable FormatSuffixes stores the format in
+const FormatParam = "format"
+
+// FormatSuffixes lets every route be requested with one of suffixes (e.g.
+// "json" or "xml") added to the path, as in /users.json. If a path ends with
+// "." and one of the suffixes, the suffix is removed, stored in the
+// FormatParam variable and the rest of the path is matched. Only if that
+// doesn't match a route is the whole path matched, so /u/<id> gets id "5"
+// for /u/5.json. A route registered for the whole path, as in /data.json, is
+// matched first. Variables of the route come before it in ParamValues.
+func (rtr *Router) FormatSuffixes(suffixes []string) {
+	rtr.formats = suffixes
+}
+
+// splitFormat returns path without a suffix from FormatSuffixes and the
+// suffix, or "" if it doesn't have one
+func (rtr *Router) splitFormat(path string) (string, string) {
+	for _, s := range rtr.formats {
+		if base := strings.TrimSuffix(path, "."+s); base != path && base != "" {
+			return base, s
+		}
+	}
+	return path, ""
+}
+
+// withFormat wraps f so the request has the FormatParam variable
+func withFormat(f http.HandlerFunc, format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := FormatParam + "=" + url.QueryEscape(format)
+		if r.URL.RawQuery != "" {
+			q += "&" + r.URL.RawQuery
+		}
+		r.URL.RawQuery = q
+		r = withParams(r, []string{FormatParam}, []string{format})
+		setRouted(w, r)
+		f(w, r)
+	}
+}
@@ -0,0 +1,57 @@
+package yar
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestFormatSuffixes(t *testing.T) {
+	rtr := NewRouter()
+	rtr.FormatSuffixes([]string{"json", "xml"})
+	rtr.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "users ", Param(r, FormatParam))
+	})
+	rtr.HandleFunc("/u/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ParamValues(r), " ", r.URL.RawQuery)
+	})
+	rtr.HandleFunc("/a.json", write("exact"))
+	tests := map[string]string{
+		"/users":          "users ",
+		"/users.json":     "users json",
+		"/users.json?x=1": "users json",
+		"/u/5.xml":        "[5 xml] id=5&format=xml",
+		"/a.json":         "exact",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+	if w := serve(rtr, "GET", "/users.txt"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /users.txt = %d, want 404", w.Code)
+	}
+}
+
+func TestFormatSuffixesExactRoute(t *testing.T) {
+	// without CheckRegexp /data.json is a fixed route, with it a regexp
+	for _, check := range []bool{false, true} {
+		rtr := NewRouter()
+		rtr.CheckRegexp = check
+		rtr.FormatSuffixes([]string{"json"})
+		rtr.HandleFunc("/data.json", write("exact"))
+		rtr.HandleFunc("/<name>", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "name ", Param(r, "name"))
+		})
+		if got := serve(rtr, "GET", "/data.json").Body.String(); got != "exact" {
+			t.Errorf("CheckRegexp %v: GET /data.json = %q, want the exact route", check, got)
+		}
+		if got := serve(rtr, "GET", "/other.json").Body.String(); got != "name other" {
+			t.Errorf("CheckRegexp %v: GET /other.json = %q", check, got)
+		}
+		rtr.Host("a.com").HandleFunc("/host.json", write("host"))
+		if got := serveHost(rtr, "a.com", "/host.json").Body.String(); got != "host" {
+			t.Errorf("CheckRegexp %v: GET /host.json on a.com = %q, want the host route", check, got)
+		}
+	}
+}
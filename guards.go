@@ -0,0 +1,152 @@
+package yar
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+)
+
+// addGuardedRoute registers f for pattern, but only for requests guard
+// returns true for, guard is passed the path that was matched. Other
+// requests fall through to the rest of the routes. Guarded routes are
+// checked before all others and the same pattern can be registered with
+// several guards.
+func (rtr *Router) addGuardedRoute(pattern string, guard func(*http.Request, string) bool, f http.HandlerFunc) error {
+	route, err := rtr.newGuardedRoute(pattern, f)
+	if err != nil {
+		return err
+	}
+	route.guard = guard
+	rtr.insertGuardedRoute(route)
+	return nil
+}
+
+// newGuardedRoute returns the route for pattern, which isn't guarded yet
+func (rtr *Router) newGuardedRoute(pattern string, f http.HandlerFunc) (*Route, error) {
+	var route *Route
+	if paramRegexp.MatchString(pattern) {
+		var err error
+		if route, err = rtr.newParameterRoute(pattern, paramRegexp, f); err != nil {
+			return nil, err
+		}
+	} else if rtr.CheckRegexp && regexp.QuoteMeta(pattern) != pattern {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		route = &Route{Pattern: re, Func: f}
+	} else {
+		// a fixed route has to match the whole path
+		route = &Route{Pattern: regexp.MustCompile(guardedKey(pattern)), Func: f}
+	}
+	route.setPrefix()
+	return route, nil
+}
+
+// guardedKey returns the key a guarded route for the fixed pattern is stored
+// under
+func guardedKey(pattern string) string {
+	return "^" + regexp.QuoteMeta(pattern) + "$"
+}
+
+func (rtr *Router) insertGuardedRoute(route *Route) {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	rtr.guarded = append(rtr.guarded, route)
+	sort.Stable(rtr.guarded)
+}
+
+// HandleWhen registers f for pattern, but the route only matches requests
+// that pred returns true for, pred is called once the path matches. Other
+// requests fall through to the other routes, e.g. the normal handler for
+// the same pattern. The more specific HandleCookie and HandleHTTP2 are
+// built on it.
+func (rtr *Router) HandleWhen(pattern string, pred func(*http.Request) bool, f http.HandlerFunc) error {
+	return rtr.addGuardedRoute(pattern, func(r *http.Request, path string) bool {
+		return pred(r)
+	}, f)
+}
+
+// HandleCookie registers f for pattern, but only for requests that have the
+// cookie cookieName, with the value cookieValue unless it is "". Requests
+// without it fall through to the other routes, e.g. the normal handler for
+// the same pattern.
+func (rtr *Router) HandleCookie(pattern, cookieName, cookieValue string, f http.HandlerFunc) error {
+	return rtr.HandleWhen(pattern, func(r *http.Request) bool {
+		c, err := r.Cookie(cookieName)
+		return err == nil && (cookieValue == "" || c.Value == cookieValue)
+	}, f)
+}
+
+// HandleValidated registers f for pattern, a pattern with variables, but only
+// if the validator for each variable in validators accepts its value, e.g. to
+// check an ID exists. If one doesn't the request falls through to the other
+// routes, or if ValidationFailed is set that is called instead.
+func (rtr *Router) HandleValidated(pattern string, validators map[string]func(string) bool, f http.HandlerFunc) error {
+	route, err := rtr.newGuardedRoute(pattern, nil)
+	if err != nil {
+		return err
+	}
+	if route.params == nil {
+		return errors.New("Pattern has no variables: " + pattern)
+	}
+	for name := range validators {
+		if !containsString(route.params.VarNames, name) {
+			return errors.New("No variable <" + name + "> in " + pattern)
+		}
+	}
+	valid := func(names, values []string) bool {
+		for i, name := range names {
+			if v, ok := validators[name]; ok && !v(values[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	route.params.Func = func(w http.ResponseWriter, r *http.Request) {
+		if rtr.ValidationFailed != nil {
+			// the guard let it through so it is validated here
+			if p := getParams(r); !valid(p.names, p.values) {
+				rtr.ValidationFailed(w, r)
+				return
+			}
+		}
+		f(w, r)
+	}
+	route.guard = func(r *http.Request, path string) bool {
+		if rtr.ValidationFailed != nil {
+			return true
+		}
+		match := route.Pattern.FindStringSubmatch(path)
+		return match != nil && valid(route.params.VarNames, match[1:])
+	}
+	rtr.insertGuardedRoute(route)
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleHTTP2 registers f for pattern, but only for requests made over
+// HTTP/2 or later (see IsHTTP2). Other requests fall through to the other
+// routes, e.g. an HTTP/1 handler for the same pattern.
+func (rtr *Router) HandleHTTP2(pattern string, f http.HandlerFunc) error {
+	return rtr.HandleWhen(pattern, IsHTTP2, f)
+}
+
+// HandleIfBody registers f for pattern, but only for requests that have a
+// body if hasBody is true, or that don't if it is false (see HasBody).
+// Other requests fall through to the other routes, e.g. a handler for the
+// same pattern registered with the opposite hasBody.
+func (rtr *Router) HandleIfBody(pattern string, hasBody bool, f http.HandlerFunc) error {
+	return rtr.HandleWhen(pattern, func(r *http.Request) bool {
+		return HasBody(r) == hasBody
+	}, f)
+}
@@ -0,0 +1,167 @@
+package yar
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleCookie(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/app", write("normal"))
+	rtr.HandleCookie("/app", "beta", "", write("beta"))
+	rtr.HandleCookie("/u/<id>$", "beta", "1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("u" + Param(r, "id")))
+	})
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		return w
+	}
+	tests := []struct {
+		path   string
+		cookie *http.Cookie
+		code   int
+		body   string
+	}{
+		{"/app", nil, http.StatusOK, "normal"},
+		{"/app", &http.Cookie{Name: "beta", Value: "x"}, http.StatusOK, "beta"},
+		{"/app", &http.Cookie{Name: "other", Value: "x"}, http.StatusOK, "normal"},
+		{"/u/3", &http.Cookie{Name: "beta", Value: "1"}, http.StatusOK, "u3"},
+		{"/u/3", &http.Cookie{Name: "beta", Value: "2"}, http.StatusNotFound, ""},
+		{"/u/3", nil, http.StatusNotFound, ""},
+		{"/appx", &http.Cookie{Name: "beta", Value: "x"}, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := get(tt.path, tt.cookie)
+		if w.Code != tt.code || tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s with %v = %d %q, want %d %q", tt.path, tt.cookie, w.Code, w.Body, tt.code, tt.body)
+		}
+	}
+}
+
+func TestHandleValidated(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/u/<id>$", write("fallback"))
+	calls := 0
+	validators := map[string]func(string) bool{
+		"id": func(s string) bool { calls++; return s == "1" },
+	}
+	err := rtr.HandleValidated("/u/<id>$", validators, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok " + Param(r, "id")))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "GET", "/u/1").Body.String(); got != "ok 1" {
+		t.Errorf("valid id = %q", got)
+	}
+	if got := serve(rtr, "GET", "/u/2").Body.String(); got != "fallback" {
+		t.Errorf("invalid id = %q, want the route it falls through to", got)
+	}
+
+	rtr.ValidationFailed = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) }
+	calls = 0
+	if w := serve(rtr, "GET", "/u/2"); w.Code != http.StatusBadRequest || calls != 1 {
+		t.Errorf("invalid id with ValidationFailed = %d after %d calls", w.Code, calls)
+	}
+
+	if err := rtr.HandleValidated("/v/<id>", map[string]func(string) bool{"x": nil}, write("")); err == nil {
+		t.Error("validator for a variable the pattern doesn't have registered")
+	}
+	if err := rtr.HandleValidated("/v", nil, write("")); err == nil {
+		t.Error("pattern without variables registered")
+	}
+}
+
+func TestHandleHTTP2(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/p", write("1"))
+	rtr.HandleHTTP2("/p", write("2"))
+	tests := map[string]string{
+		"HTTP/1.0": "1",
+		"HTTP/1.1": "1",
+		"HTTP/2.0": "2",
+		"HTTP/3.0": "2",
+	}
+	for proto, want := range tests {
+		r := httptest.NewRequest("GET", "/p", nil)
+		r.Proto = proto
+		r.ProtoMajor, r.ProtoMinor, _ = http.ParseHTTPVersion(proto)
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		if w.Body.String() != want {
+			t.Errorf("%s: GET /p = %q, want %q", proto, w.Body, want)
+		}
+		if IsHTTP2(r) != (want == "2") {
+			t.Errorf("%s: IsHTTP2 = %v", proto, IsHTTP2(r))
+		}
+	}
+}
+
+func TestHandleWhen(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/x", write("plain"))
+	beta := func(r *http.Request) bool { return r.Header.Get("X-Beta") == "1" }
+	if err := rtr.HandleWhen("/x", beta, write("beta")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rtr.HandleWhen("/only", beta, write("only")); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, header string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			r.Header.Set("X-Beta", header)
+		}
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		return w
+	}
+	if got := get("/x", "1").Body.String(); got != "beta" {
+		t.Errorf("GET /x with the header = %q", got)
+	}
+	if got := get("/x", "0").Body.String(); got != "plain" {
+		t.Errorf("GET /x with another value = %q", got)
+	}
+	if got := get("/x", "").Body.String(); got != "plain" {
+		t.Errorf("GET /x without the header = %q", got)
+	}
+	if w := get("/only", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /only without the header = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleIfBody(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleIfBody("/b", true, func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("body:"), b...))
+	})
+	rtr.HandleIfBody("/b", false, write("empty"))
+	tests := []struct {
+		body   io.Reader
+		length int64
+		want   string
+	}{
+		{nil, 0, "empty"},
+		{strings.NewReader("xyz"), 3, "body:xyz"},
+		{io.MultiReader(strings.NewReader("chunk")), -1, "body:chunk"},
+		{io.MultiReader(), -1, "empty"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/b", tt.body)
+		r.ContentLength = tt.length
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		if w.Body.String() != tt.want {
+			t.Errorf("POST /b with length %d = %q, want %q", tt.length, w.Body, tt.want)
+		}
+	}
+}
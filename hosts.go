@@ -0,0 +1,96 @@
+package yar
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Host returns the Router used for requests to host, creating it if needed.
+// Its routes are checked before those of rtr, if none of them match the
+// request falls back to the routes of rtr. Requests that get no response
+// from it, e.g. a 405 or an index out of range for HandleSelect, go to the
+// NoMatch, NotFound and MethodNotAllowed of rtr. The flags for registering
+// and matching routes (CheckRegexp, DuplicateParams, StripPatternQuery,
+// StrictRoutes, NormalizeMethod, RegexFirst and UseEscapedPath) are copied
+// from rtr when the host router is created, so set them before calling Host.
+func (rtr *Router) Host(host string) *Router {
+	host = normalizeHost(host)
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	if rtr.hosts == nil {
+		rtr.hosts = map[string]*Router{}
+	}
+	sub, exists := rtr.hosts[host]
+	if !exists {
+		sub = NewRouter()
+		sub.parent = rtr
+		sub.CheckRegexp = rtr.CheckRegexp
+		sub.DuplicateParams = rtr.DuplicateParams
+		sub.StripPatternQuery = rtr.StripPatternQuery
+		sub.StrictRoutes = rtr.StrictRoutes
+		sub.NormalizeMethod = rtr.NormalizeMethod
+		sub.RegexFirst = rtr.RegexFirst
+		sub.UseEscapedPath = rtr.UseEscapedPath
+		rtr.hosts[host] = sub
+	}
+	return sub
+}
+
+// HostGroup registers routes for several hosts at once
+type HostGroup struct {
+	routers []*Router
+}
+
+// Hosts returns a HostGroup that adds routes to the Router of each host
+func (rtr *Router) Hosts(hosts ...string) *HostGroup {
+	hg := HostGroup{}
+	for _, h := range hosts {
+		hg.routers = append(hg.routers, rtr.Host(h))
+	}
+	return &hg
+}
+
+// HandleFunc registers f for pattern on every host in the group
+func (hg *HostGroup) HandleFunc(pattern string, f http.HandlerFunc) error {
+	for _, sub := range hg.routers {
+		if err := sub.HandleFunc(pattern, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleMethod registers f for method and pattern on every host in the group
+func (hg *HostGroup) HandleMethod(method, pattern string, f http.HandlerFunc) error {
+	for _, sub := range hg.routers {
+		if err := sub.HandleMethod(method, pattern, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostRouter returns the Router for the host of the request or nil, and if
+// there are any host routers at all
+func (rtr *Router) hostRouter(r *http.Request) (*Router, bool) {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	if len(rtr.hosts) == 0 {
+		return nil, false
+	}
+	return rtr.hosts[normalizeHost(r.Host)], true
+}
+
+// misdirected is used with StrictHost for requests to an unknown host
+func misdirected(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+}
+
+// normalizeHost lower cases host and strips the port
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
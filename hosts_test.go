@@ -0,0 +1,86 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveHost(h http.Handler, host, path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHosts(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.Hosts("example.com", "www.example.com").HandleFunc("/h", write("host")); err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"example.com", "WWW.example.com:8080"} {
+		if got := serveHost(rtr, host, "/h").Body.String(); got != "host" {
+			t.Errorf("GET /h on %s = %q", host, got)
+		}
+	}
+	if w := serveHost(rtr, "other.com", "/h"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /h on another host = %d, want 404", w.Code)
+	}
+}
+
+func TestStrictHost(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/", write("default"))
+	rtr.Host("a.com").HandleFunc("/", write("a"))
+	rtr.StrictHost = true
+	if w := serveHost(rtr, "b.com", "/"); w.Code != http.StatusMisdirectedRequest {
+		t.Errorf("unknown host with StrictHost = %d, want 421", w.Code)
+	}
+	if got := serveHost(rtr, "A.com:80", "/").Body.String(); got != "a" {
+		t.Errorf("known host with StrictHost = %q", got)
+	}
+	rtr.StrictHost = false
+	if got := serveHost(rtr, "b.com", "/").Body.String(); got != "default" {
+		t.Errorf("unknown host without StrictHost = %q, want the default routes", got)
+	}
+}
+
+func TestHostInheritsNoMatch(t *testing.T) {
+	rtr := NewRouter()
+	reasons := []NoMatchReason{}
+	rtr.NoMatch = func(w http.ResponseWriter, r *http.Request, reason NoMatchReason) {
+		reasons = append(reasons, reason)
+		w.WriteHeader(http.StatusTeapot)
+	}
+	sub := rtr.Host("a.com")
+	sub.HandleMethod("POST", "/m", write("post"))
+	sub.HandleFlag(NewFlag(false), "", "/f", write("flag"))
+	if w := serveHost(rtr, "a.com", "/m"); w.Code != http.StatusTeapot || w.Header().Get("Allow") == "" {
+		t.Errorf("GET /m on a.com = %d with Allow %q, want 418 from NoMatch", w.Code, w.Header().Get("Allow"))
+	}
+	if w := serveHost(rtr, "a.com", "/f"); w.Code != http.StatusTeapot {
+		t.Errorf("GET /f on a.com with the flag off = %d, want 418 from NoMatch", w.Code)
+	}
+	if len(reasons) != 2 || reasons[0] != ReasonMethodNotAllowed || reasons[1] != ReasonNotFound {
+		t.Errorf("NoMatch reasons = %v", reasons)
+	}
+}
+
+func TestHostInheritsFlags(t *testing.T) {
+	rtr := NewRouter()
+	rtr.DuplicateParams = true
+	rtr.NormalizeMethod = false
+	sub := rtr.Host("a.com")
+	if err := sub.HandleFunc("/<id>/to/<id>", write("dup")); err != nil {
+		t.Errorf("duplicate variable on a host with DuplicateParams: %v", err)
+	}
+	sub.HandleMethod("GET", "/g", write("get"))
+	r := httptest.NewRequest("get", "/g", nil)
+	r.Host = "a.com"
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("get /g on a.com without NormalizeMethod = %d, want 405", w.Code)
+	}
+}
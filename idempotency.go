@@ -0,0 +1,165 @@
+package yar
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKey returns the Idempotency-Key header of r, it returns false
+// if there isn't one
+func IdempotencyKey(r *http.Request) (string, bool) {
+	key := r.Header.Get("Idempotency-Key")
+	return key, key != ""
+}
+
+// IdempotencyStore keeps the responses Idempotent replays, it keeps at most
+// size responses for ttl each and is safe to use from several handlers
+type IdempotencyStore struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	size      int
+	responses map[string]*storedResponse
+	order     *list.List // keys of responses, oldest first
+}
+
+type storedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+	done    chan struct{} // closed once the response is stored
+	elem    *list.Element // the key in order
+}
+
+// NewIdempotencyStore returns a store keeping at most size responses for ttl
+func NewIdempotencyStore(size int, ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl, size: size, responses: map[string]*storedResponse{}, order: list.New()}
+}
+
+// Idempotent returns middleware that stores the response to a request with
+// an IdempotencyKey and replays it for later requests with the same key and
+// method, so a client retrying a POST doesn't repeat its side effects. A
+// retry that arrives while the first request is still being handled waits
+// for it. Requests without a key are handled as usual, as are those whose
+// response was an error (5xx), so they can be retried.
+func Idempotent(store *IdempotencyStore) Middleware {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key, ok := IdempotencyKey(r)
+			if !ok {
+				f(w, r)
+				return
+			}
+			key = r.Method + " " + r.URL.Path + " " + key
+			stored, first := store.start(key)
+			if !first {
+				<-stored.done
+				if stored.body != nil {
+					w.Header().Set("Idempotent-Replayed", "true")
+					stored.replay(w)
+					return
+				}
+				// the first request failed, so this one is handled
+				f(w, r)
+				return
+			}
+			rec := &recordingWriter{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if !completed {
+					// the handler panicked, don't store what it wrote
+					rec.status = http.StatusInternalServerError
+				}
+				store.finish(key, stored, rec)
+			}()
+			f(rec, r)
+			completed = true
+		}
+	}
+}
+
+// start returns the response stored for key, or an empty one that is added
+// for the caller to fill in if there is none
+func (s *IdempotencyStore) start(key string) (*storedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if stored, ok := s.responses[key]; ok {
+		if now.Before(stored.expires) {
+			return stored, false
+		}
+		s.remove(key, stored)
+	}
+	// drop expired responses, and the oldest ones if the store is full
+	for front := s.order.Front(); front != nil; front = s.order.Front() {
+		oldest := front.Value.(string)
+		if now.Before(s.responses[oldest].expires) && len(s.responses) < s.size {
+			break
+		}
+		s.remove(oldest, s.responses[oldest])
+	}
+	stored := &storedResponse{expires: now.Add(s.ttl), done: make(chan struct{})}
+	stored.elem = s.order.PushBack(key)
+	s.responses[key] = stored
+	return stored, true
+}
+
+// remove drops the response stored for key, s.mu must be held
+func (s *IdempotencyStore) remove(key string, stored *storedResponse) {
+	delete(s.responses, key)
+	s.order.Remove(stored.elem)
+}
+
+// finish stores the recorded response, unless it was an error
+func (s *IdempotencyStore) finish(key string, stored *storedResponse, rec *recordingWriter) {
+	s.mu.Lock()
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if rec.status < 500 {
+		stored.status = rec.status
+		stored.header = rec.Header().Clone()
+		stored.body = append([]byte{}, rec.body.Bytes()...)
+	} else if s.responses[key] == stored {
+		s.remove(key, stored)
+	}
+	s.mu.Unlock()
+	close(stored.done)
+}
+
+func (stored *storedResponse) replay(w http.ResponseWriter) {
+	for k, v := range stored.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(stored.status)
+	w.Write(stored.body)
+}
+
+// recordingWriter keeps a copy of what is written
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recordingWriter) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *recordingWriter) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *recordingWriter) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,116 @@
+package yar
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func postWithKey(h http.Handler, path, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, nil)
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	rtr := NewRouter()
+	calls := 0
+	rtr.Use(Idempotent(NewIdempotencyStore(10, time.Minute)))
+	rtr.HandleFunc("/pay", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "paid ", calls)
+	})
+
+	first := postWithKey(rtr, "/pay", "k1")
+	second := postWithKey(rtr, "/pay", "k1")
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay has no Idempotent-Replayed header")
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("first response has an Idempotent-Replayed header")
+	}
+
+	postWithKey(rtr, "/pay", "")
+	postWithKey(rtr, "/pay", "k2")
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+}
+
+func TestIdempotentErrorNotStored(t *testing.T) {
+	rtr := NewRouter()
+	calls := 0
+	rtr.Use(Idempotent(NewIdempotencyStore(10, time.Minute)))
+	rtr.HandleFunc("/pay", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	if w := postWithKey(rtr, "/pay", "k"); w.Code != http.StatusBadGateway {
+		t.Fatalf("first = %d", w.Code)
+	}
+	if w := postWithKey(rtr, "/pay", "k"); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry = %d after %d calls, want it handled again", w.Code, calls)
+	}
+}
+
+func TestIdempotencyStoreBounded(t *testing.T) {
+	store := NewIdempotencyStore(10, time.Minute)
+	rtr := NewRouter()
+	rtr.Use(Idempotent(store))
+	rtr.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	rtr.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	postWithKey(rtr, "/ok", "live")
+	for i := 0; i < 1000; i++ {
+		postWithKey(rtr, "/fail", fmt.Sprint(i))
+	}
+	if store.order.Len() != 1 || len(store.responses) != 1 {
+		t.Errorf("store has %d keys in order and %d responses, want 1", store.order.Len(), len(store.responses))
+	}
+	for i := 0; i < 20; i++ {
+		postWithKey(rtr, "/ok", fmt.Sprint("k", i))
+	}
+	if store.order.Len() != 10 || len(store.responses) != 10 {
+		t.Errorf("store has %d keys in order and %d responses, want 10", store.order.Len(), len(store.responses))
+	}
+}
+
+func TestIdempotencyStoreExpired(t *testing.T) {
+	store := NewIdempotencyStore(10, 20*time.Millisecond)
+	rtr := NewRouter()
+	calls := 0
+	rtr.Use(Idempotent(store))
+	rtr.HandleFunc("/pay", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, calls)
+	})
+	postWithKey(rtr, "/pay", "k")
+	time.Sleep(30 * time.Millisecond)
+	if w := postWithKey(rtr, "/pay", "k"); w.Body.String() != "2" {
+		t.Fatalf("expired key replayed %q", w.Body)
+	}
+	if store.order.Len() != 1 {
+		t.Fatalf("re-started key is in order %d times", store.order.Len())
+	}
+	if w := postWithKey(rtr, "/pay", "k"); w.Body.String() != "2" {
+		t.Errorf("fresh response for a re-started key not replayed, got %q", w.Body)
+	}
+}
@@ -0,0 +1,72 @@
+package yar
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// LogFormat is the format of the lines logged for requests
+type LogFormat int
+
+const (
+	LogText LogFormat = iota // "requested: /path", logged before the handler runs
+	LogJSON                  // a JSON object, logged after the handler returns
+)
+
+// Logger is where the Router writes its log lines, *log.Logger implements it
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// accessLog is a request logged with LogJSON
+type accessLog struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Pattern    string  `json:"pattern"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	WriteError string  `json:"write_error,omitempty"`
+}
+
+// ignoreLog reports if path is in IgnoreLogPaths
+func (rtr *Router) ignoreLog(path string) bool {
+	for _, p := range rtr.IgnoreLogPaths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// logln writes to Logger, or the standard logger if it isn't set
+func (rtr *Router) logln(v ...interface{}) {
+	if rtr.Logger != nil {
+		rtr.Logger.Println(v...)
+		return
+	}
+	log.Println(v...)
+}
+
+func (rtr *Router) logJSON(r *http.Request, path, pattern string, rw *responseWriter) {
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	entry := accessLog{
+		Method:     r.Method,
+		Path:       path,
+		Pattern:    pattern,
+		Status:     status,
+		DurationMs: float64(time.Since(rw.start)) / float64(time.Millisecond),
+	}
+	if rw.writeErr != nil {
+		entry.WriteError = rw.writeErr.Error()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	rtr.logln(string(line))
+}
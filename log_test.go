@@ -0,0 +1,78 @@
+package yar
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLogSanitized(t *testing.T) {
+	rtr := NewRouter()
+	var logged lines
+	rtr.Log = true
+	rtr.Logger = &logged
+	rtr.HandleFunc("/u/<name>", write(""))
+	serve(rtr, "GET", "/u/a%0Afake:%20line%00")
+	if len(logged) != 1 {
+		t.Fatalf("logged %q, want one line", logged)
+	}
+	if strings.ContainsAny(logged[0], "\n\r\x00") {
+		t.Errorf("log line %q has control characters", logged[0])
+	}
+	if want := `requested: /u/a\nfake: line\x00`; logged[0] != want {
+		t.Errorf("log line = %q, want %q", logged[0], want)
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	rtr := NewRouter()
+	var logged lines
+	rtr.Log = true
+	rtr.Logger = &logged
+	rtr.LogFormat = LogJSON
+	rtr.HandleFunc("/u/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	serve(rtr, "POST", "/u/1")
+	serve(rtr, "GET", "/missing")
+	if len(logged) != 2 {
+		t.Fatalf("logged %q, want two lines", logged)
+	}
+	want := []accessLog{
+		{Method: "POST", Path: "/u/1", Pattern: "/u/([^/]+)$", Status: http.StatusCreated},
+		{Method: "GET", Path: "/missing", Status: http.StatusNotFound},
+	}
+	for i, line := range logged {
+		var got accessLog
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Errorf("line %q isn't JSON: %v", line, err)
+			continue
+		}
+		if got.DurationMs < 0 {
+			t.Errorf("line %q has a negative duration", line)
+		}
+		got.DurationMs = 0
+		if got != want[i] {
+			t.Errorf("line %q, want %+v", line, want[i])
+		}
+	}
+}
+
+func TestIgnoreLogPaths(t *testing.T) {
+	for _, format := range []LogFormat{LogText, LogJSON} {
+		rtr := NewRouter()
+		var logged lines
+		rtr.Log = true
+		rtr.Logger = &logged
+		rtr.LogFormat = format
+		rtr.IgnoreLogPaths = []string{"/wp-login.php", "/healthz"}
+		rtr.HandleFunc("/healthz", write("ok"))
+		serve(rtr, "GET", "/wp-login.php")
+		serve(rtr, "GET", "/healthz")
+		serve(rtr, "GET", "/x")
+		if len(logged) != 1 || !strings.Contains(logged[0], "/x") {
+			t.Errorf("format %d: logged %q, want only /x", format, logged)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package yar
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NoMatchReason is passed to Router.NoMatch to say why no route was found
+type NoMatchReason int
+
+const (
+	ReasonNotFound         NoMatchReason = iota // no route matched the path
+	ReasonMethodNotAllowed                      // the path matched but not the method
+)
+
+// methodRoute holds the functions registered for each method of a pattern
+// with HandleMethod, and for each Content-Type with HandleContentType
+type methodRoute struct {
+	rtr          *Router
+	handlers     map[string]http.HandlerFunc
+	contentTypes map[string]http.HandlerFunc // "" is the default
+}
+
+// ServeHTTP matches the method first, any method matches if none were
+// registered. Then the function for the Content-Type of the body is used if
+// there is one, otherwise that of the method, or the default for content
+// types. Without one the request gets a 415.
+func (mr *methodRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := mr.rtr.method(r)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mr.rtr.mu.RLock()
+	f, ok := mr.handlers[method]
+	get, hasGet := mr.handlers[http.MethodGet]
+	anyMethod := len(mr.handlers) == 0
+	byType, typed := mr.contentTypes[mediaType]
+	if !typed && !ok {
+		byType, typed = mr.contentTypes[""]
+	}
+	mr.rtr.mu.RUnlock()
+	if !ok && !anyMethod {
+		if method == http.MethodHead && hasGet {
+			serveHead(get, w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(mr.allowed(), ", "))
+		mr.rtr.methodNotAllowed(w, r)
+		return
+	}
+	if typed {
+		byType(w, r)
+		return
+	}
+	if ok {
+		f(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+}
+
+// allowed returns the sorted methods that have a function, including HEAD
+// if GET has one, or nil if the route takes any method
+func (mr *methodRoute) allowed() []string {
+	mr.rtr.mu.RLock()
+	if len(mr.handlers) == 0 {
+		mr.rtr.mu.RUnlock()
+		return nil
+	}
+	methods := []string{}
+	for m := range mr.handlers {
+		methods = append(methods, m)
+	}
+	_, hasGet := mr.handlers[http.MethodGet]
+	_, hasHead := mr.handlers[http.MethodHead]
+	mr.rtr.mu.RUnlock()
+	if hasGet && !hasHead {
+		methods = append(methods, http.MethodHead)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// HandleMethod registers f for requests to pattern that use method. Requests
+// to the pattern with a method that has nothing registered get a 405, except
+// HEAD which is answered by the GET function if there is one (see
+// serveHead).
+func (rtr *Router) HandleMethod(method, pattern string, f http.HandlerFunc) error {
+	if rtr.NormalizeMethod {
+		method = strings.ToUpper(method)
+	}
+	return rtr.addToMethodRoute(pattern, func(mr *methodRoute) {
+		mr.handlers[method] = f
+	})
+}
+
+// addToMethodRoute calls add with rtr.mu held to add a function to the
+// methodRoute of pattern, which is registered if there isn't one yet
+func (rtr *Router) addToMethodRoute(pattern string, add func(*methodRoute)) error {
+	if path, ok := rtr.patternQuery(pattern); ok && rtr.StripPatternQuery {
+		// HandleFunc registers it without the query, so key it the same way
+		pattern = path
+	}
+	key := routeKey(pattern)
+	rtr.mu.Lock()
+	if mr, exists := rtr.methods[key]; exists {
+		add(mr)
+		rtr.mu.Unlock()
+		return nil
+	}
+	rtr.mu.Unlock()
+	mr := &methodRoute{rtr, map[string]http.HandlerFunc{}, map[string]http.HandlerFunc{}}
+	add(mr)
+	if err := rtr.HandleFunc(pattern, mr.ServeHTTP); err != nil {
+		return err
+	}
+	rtr.mu.Lock()
+	if rtr.methods == nil {
+		rtr.methods = map[string]*methodRoute{}
+	}
+	rtr.methods[key] = mr
+	rtr.mu.Unlock()
+	return nil
+}
+
+// method returns the method of r used for matching, upper cased if
+// NormalizeMethod is set
+func (rtr *Router) method(r *http.Request) string {
+	if rtr.NormalizeMethod {
+		return strings.ToUpper(r.Method)
+	}
+	return r.Method
+}
+
+// Resource registers a function for each method of pattern, they share one
+// route and other methods get a 405
+func (rtr *Router) Resource(pattern string, handlers map[string]http.HandlerFunc) error {
+	for method, f := range handlers {
+		if err := rtr.HandleMethod(method, pattern, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllowedMethods returns the methods registered with HandleMethod for the
+// route that path matches, going by the path the way ServeHTTP does. It
+// returns nil if no route matches or the route was registered for all
+// methods. Guarded routes need a request to be matched so they are skipped,
+// as are the routes of hosts; AllowedMethodsFor matches both.
+func (rtr *Router) AllowedMethods(path string) []string {
+	return rtr.allowedMethods(nil, nil, rtr.stripPath(path))
+}
+
+// AllowedMethodsFor is AllowedMethods for the route r would be routed to,
+// including guarded routes and the routes of the Host of r
+func (rtr *Router) AllowedMethodsFor(r *http.Request) []string {
+	path := r.URL.Path
+	if rtr.UseEscapedPath {
+		path = r.URL.EscapedPath()
+	}
+	sub, _ := rtr.hostRouter(r)
+	return rtr.allowedMethods(sub, r, rtr.stripPath(path))
+}
+
+// allowedMethods returns the methods of the route path matches, trying the
+// host router sub first and the path without a format suffix before the
+// full path, as route does
+func (rtr *Router) allowedMethods(sub *Router, r *http.Request, path string) []string {
+	paths := []string{path}
+	if base, format := rtr.splitFormat(path); format != "" {
+		paths = []string{base, path}
+	}
+	for _, p := range paths {
+		for _, owner := range []*Router{sub, rtr} {
+			if owner == nil {
+				continue
+			}
+			if f, key := owner.match(r, p); f != nil {
+				owner.mu.RLock()
+				mr, ok := owner.methods[key]
+				owner.mu.RUnlock()
+				if ok {
+					return mr.allowed()
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (rtr *Router) notFound(w http.ResponseWriter, r *http.Request) {
+	if rtr.parent != nil {
+		rtr.parent.notFound(w, r)
+		return
+	}
+	r = withRouter(r, rtr)
+	setRouted(w, r)
+	if rtr.NoMatch != nil {
+		rtr.NoMatch(w, r, ReasonNotFound)
+		return
+	}
+	rtr.NotFound(w, r)
+}
+
+func (rtr *Router) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if rtr.parent != nil {
+		rtr.parent.methodNotAllowed(w, r)
+		return
+	}
+	if rtr.NoMatch != nil {
+		rtr.NoMatch(w, r, ReasonMethodNotAllowed)
+		return
+	}
+	rtr.MethodNotAllowed(w, r)
+}
+
+// MethodNotAllowed replies to the request with an HTTP 405 error
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+}
+
+// HandleContentType registers f for requests to pattern with a body of
+// contentType. Only the media type is compared so parameters like charset
+// are ignored. The content type is checked once the path and method have
+// matched: on a pattern with methods registered with HandleMethod, f is used
+// for all of them and the function of the method is the default otherwise.
+// Registering with an empty contentType sets the default for a pattern
+// without methods, without one those requests get a 415.
+func (rtr *Router) HandleContentType(pattern, contentType string, f http.HandlerFunc) error {
+	contentType = strings.ToLower(contentType)
+	return rtr.addToMethodRoute(pattern, func(mr *methodRoute) {
+		mr.contentTypes[contentType] = f
+	})
+}
+
+// serveHead answers a HEAD request with the GET function get. The body it
+// writes is counted and dropped, and if get didn't set a Content-Length the
+// count is sent as one, so it is the same as for GET. This means holding
+// back the headers until get returns and doing all the work of a GET; a
+// handler that wants to avoid that should register HEAD itself. If get
+// sets Content-Length it is trusted, and if it flushes the headers are sent
+// at that point without one.
+func serveHead(get http.HandlerFunc, w http.ResponseWriter, r *http.Request) {
+	hw := &headWriter{ResponseWriter: w}
+	get(hw, r)
+	if !hw.sent {
+		if hw.Header().Get("Content-Length") == "" && hw.Header().Get("Transfer-Encoding") == "" {
+			hw.Header().Set("Content-Length", strconv.FormatInt(hw.size, 10))
+		}
+		hw.send()
+	}
+}
+
+// headWriter holds back the headers and counts the body instead of writing it
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	sent   bool
+}
+
+func (hw *headWriter) WriteHeader(code int) {
+	if hw.status == 0 {
+		hw.status = code
+	}
+}
+
+func (hw *headWriter) Write(b []byte) (int, error) {
+	hw.size += int64(len(b))
+	return len(b), nil
+}
+
+func (hw *headWriter) Flush() {
+	if !hw.sent {
+		hw.send()
+	}
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *headWriter) send() {
+	hw.sent = true
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
@@ -0,0 +1,233 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAllowedMethods(t *testing.T) {
+	rtr := NewRouter()
+	h := write("")
+	rtr.HandleMethod("GET", "/items/<id>", h)
+	rtr.HandleMethod("POST", "/items/<id>", h)
+	rtr.HandleMethod("GET", "/x", h)
+	rtr.HandleCookie("/x", "beta", "", h)
+	rtr.HandleFunc("/any", h)
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/items/5", []string{"GET", "HEAD", "POST"}},
+		{"/x", []string{"GET", "HEAD"}},
+		{"/any", nil},
+		{"/missing", nil},
+	}
+	for _, tt := range tests {
+		if got := rtr.AllowedMethods(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AllowedMethods(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedMethodsFor(t *testing.T) {
+	rtr := NewRouter()
+	h := write("")
+	rtr.FormatSuffixes([]string{"json"})
+	rtr.HandleMethod("GET", "/x", h)
+	rtr.HandleCookie("/x", "beta", "", h)
+	rtr.Host("api.example.com").HandleMethod("PUT", "/y", h)
+	rtr.HandleMethod("DELETE", "/users", h)
+	tests := []struct {
+		host, path string
+		cookie     bool
+		want       []string
+	}{
+		{"example.com", "/x", false, []string{"GET", "HEAD"}},
+		{"example.com", "/x", true, nil}, // the guarded route takes any method
+		{"api.example.com:8080", "/y", false, []string{"PUT"}},
+		{"example.com", "/y", false, nil},
+		{"example.com", "/users.json", false, []string{"DELETE"}},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("OPTIONS", tt.path, nil)
+		r.Host = tt.host
+		if tt.cookie {
+			r.AddCookie(&http.Cookie{Name: "beta", Value: "1"})
+		}
+		if got := rtr.AllowedMethodsFor(r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AllowedMethodsFor(%s%s, cookie %v) = %v, want %v", tt.host, tt.path, tt.cookie, got, tt.want)
+		}
+	}
+}
+
+func postBody(h http.Handler, method, path, contentType string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader("body"))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleContentType(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleContentType("/hook", "application/json", write("json"))
+	rtr.HandleContentType("/hook", "application/x-www-form-urlencoded", write("form"))
+	rtr.HandleContentType("/strict", "application/json", write("json"))
+	tests := []struct {
+		path, contentType string
+		status            int
+		body              string
+	}{
+		{"/hook", "application/json", http.StatusOK, "json"},
+		{"/hook", "application/json; charset=utf-8", http.StatusOK, "json"},
+		{"/hook", "Application/JSON", http.StatusOK, "json"},
+		{"/hook", "application/x-www-form-urlencoded", http.StatusOK, "form"},
+		{"/strict", "text/plain", http.StatusUnsupportedMediaType, ""},
+		{"/strict", "", http.StatusUnsupportedMediaType, ""},
+	}
+	for _, tt := range tests {
+		w := postBody(rtr, "POST", tt.path, tt.contentType)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("POST %s as %q = %d %q, want %d %q", tt.path, tt.contentType, w.Code, w.Body, tt.status, tt.body)
+		}
+	}
+
+	rtr.HandleContentType("/hook", "", write("default"))
+	if got := postBody(rtr, "POST", "/hook", "text/csv").Body.String(); got != "default" {
+		t.Errorf("unknown content type with a default = %q", got)
+	}
+}
+
+func TestHandleContentTypeWithMethods(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleMethod("POST", "/hook", write("post")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rtr.HandleContentType("/hook", "application/json", write("json")); err != nil {
+		t.Fatal(err)
+	}
+	rtr.HandleMethod("GET", "/hook", write("get"))
+	tests := []struct {
+		method, contentType string
+		status              int
+		body                string
+	}{
+		{"POST", "application/json", http.StatusOK, "json"},
+		{"POST", "application/x-www-form-urlencoded", http.StatusOK, "post"},
+		{"GET", "", http.StatusOK, "get"},
+		{"PUT", "application/json", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		w := postBody(rtr, tt.method, "/hook", tt.contentType)
+		if w.Code != tt.status || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s as %q = %d %q, want %d %q", tt.method, tt.contentType, w.Code, w.Body, tt.status, tt.body)
+		}
+	}
+	if got := rtr.AllowedMethods("/hook"); !reflect.DeepEqual(got, []string{"GET", "HEAD", "POST"}) {
+		t.Errorf("AllowedMethods = %v", got)
+	}
+}
+
+func TestNoMatch(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleMethod("GET", "/m", write("get"))
+	if w := serve(rtr, "PUT", "/m"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("PUT /m without NoMatch = %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	if w := serve(rtr, "GET", "/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /missing without NoMatch = %d", w.Code)
+	}
+
+	var reasons []NoMatchReason
+	rtr.NoMatch = func(w http.ResponseWriter, r *http.Request, reason NoMatchReason) {
+		reasons = append(reasons, reason)
+		w.WriteHeader(http.StatusTeapot)
+	}
+	if w := serve(rtr, "PUT", "/m"); w.Code != http.StatusTeapot || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("PUT /m with NoMatch = %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+	serve(rtr, "GET", "/missing")
+	serve(rtr, "GET", "/m")
+	if !reflect.DeepEqual(reasons, []NoMatchReason{ReasonMethodNotAllowed, ReasonNotFound}) {
+		t.Errorf("NoMatch reasons = %v", reasons)
+	}
+}
+
+func TestResource(t *testing.T) {
+	rtr := NewRouter()
+	err := rtr.Resource("/items/<id>", map[string]http.HandlerFunc{
+		"GET":    write("get"),
+		"PUT":    write("put"),
+		"DELETE": write("delete"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rtr.Routes) != 1 {
+		t.Errorf("%d routes registered, want 1", len(rtr.Routes))
+	}
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		if got := serve(rtr, method, "/items/1").Body.String(); got != strings.ToLower(method) {
+			t.Errorf("%s /items/1 = %q", method, got)
+		}
+	}
+	w := serve(rtr, "POST", "/items/1")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "DELETE, GET, HEAD, PUT" {
+		t.Errorf("POST /items/1 = %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestHeadContentLength(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleMethod("GET", "/f", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "1")
+		w.Write([]byte("hello "))
+		w.Write([]byte("world"))
+	})
+	rtr.HandleMethod("GET", "/set", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "3")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("abc"))
+	})
+	for _, path := range []string{"/f", "/set"} {
+		get, head := serve(rtr, "GET", path), serve(rtr, "HEAD", path)
+		if head.Code != get.Code || head.Body.Len() != 0 {
+			t.Errorf("HEAD %s = %d with %d bytes, GET = %d", path, head.Code, head.Body.Len(), get.Code)
+		}
+		want := strconv.Itoa(get.Body.Len())
+		if got := head.Header().Get("Content-Length"); got != want {
+			t.Errorf("HEAD %s: Content-Length %q, want %q", path, got, want)
+		}
+		if head.Header().Get("X-Custom") != get.Header().Get("X-Custom") {
+			t.Errorf("HEAD %s: headers %v, GET %v", path, head.Header(), get.Header())
+		}
+	}
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	rtr := NewRouter()
+	var seen string
+	rtr.HandleMethod("get", "/m", func(w http.ResponseWriter, r *http.Request) { seen = r.Method })
+	if w := serve(rtr, "get", "/m"); w.Code != http.StatusOK || seen != "get" {
+		t.Errorf("get /m = %d, handler saw %q", w.Code, seen)
+	}
+	if w := serve(rtr, "GET", "/m"); w.Code != http.StatusOK || seen != "GET" {
+		t.Errorf("GET /m = %d, handler saw %q", w.Code, seen)
+	}
+	if w := serve(rtr, "head", "/m"); w.Code != http.StatusOK {
+		t.Errorf("head /m = %d, want it served by GET", w.Code)
+	}
+
+	rtr = NewRouter()
+	rtr.NormalizeMethod = false
+	rtr.HandleMethod("GET", "/m", write(""))
+	if w := serve(rtr, "get", "/m"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("get /m without NormalizeMethod = %d, want 405", w.Code)
+	}
+}
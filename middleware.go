@@ -0,0 +1,272 @@
+package yar
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Middleware wraps a handler function, it can be used on a single route by
+// wrapping the function passed to HandleFunc or on all routes with Router.Use
+type Middleware func(http.HandlerFunc) http.HandlerFunc
+
+// chain wraps f so that the first middleware is the outermost
+func chain(f http.HandlerFunc, mw []Middleware) http.HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+	return f
+}
+
+// IPFilter returns middleware that only lets through requests from IPs in the
+// allow list and not in the deny list, others get a 403. Entries can be IPs or
+// CIDRs and deny takes precedence over allow. An empty allow list allows all.
+// The client IP is found with ClientIP using trustedProxies.
+// It panics if an entry can't be parsed.
+func IPFilter(allow []string, deny []string, trustedProxies []string) Middleware {
+	allowNets := mustParseNets(allow)
+	denyNets := mustParseNets(deny)
+	trusted := mustParseNets(trustedProxies)
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(clientIP(r, trusted))
+			if ip == nil || containsIP(denyNets, ip) ||
+				(len(allowNets) > 0 && !containsIP(allowNets, ip)) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			f(w, r)
+		}
+	}
+}
+
+func mustParseNets(entries []string) []*net.IPNet {
+	nets, err := parseNets(entries)
+	if err != nil {
+		panic("yar: " + err.Error())
+	}
+	return nets
+}
+
+// parseNets parses IPs and CIDRs, an IP becomes a network of just itself.
+// Entries that can't be parsed are skipped and the first error is returned.
+func parseNets(entries []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	var first error
+	for _, e := range entries {
+		if !strings.Contains(e, "/") {
+			ip := net.ParseIP(e)
+			if ip == nil {
+				if first == nil {
+					first = errors.New("invalid IP: " + e)
+				}
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(e)
+		if err != nil {
+			if first == nil {
+				first = errors.New("invalid CIDR: " + e)
+			}
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets, first
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheControl returns middleware that sets Cache-Control, and Expires for
+// HTTP/1.0 caches, so responses can be cached for maxAge. public allows
+// shared caches to store them, otherwise only the client may. A maxAge of 0
+// means the response must not be stored at all.
+func CacheControl(maxAge time.Duration, public bool) Middleware {
+	value := "no-store"
+	if maxAge > 0 {
+		value = "private"
+		if public {
+			value = "public"
+		}
+		value += ", max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	}
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			if maxAge > 0 {
+				w.Header().Set("Expires", time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+			} else {
+				w.Header().Set("Expires", "0")
+			}
+			f(w, r)
+		}
+	}
+}
+
+// ReadTimeout returns middleware that sets a read deadline of d on the
+// connection when the handler starts, this protects handlers from clients
+// that send the body slowly. If the deadline is hit while the handler reads
+// the body and nothing has been written yet the client gets a 408.
+// The underlying ResponseWriter must support http.ResponseController.
+func ReadTimeout(d time.Duration) Middleware {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(d)); err != nil {
+				f(w, r)
+				return
+			}
+			tw := &timeoutWriter{ResponseWriter: w}
+			if r.Body != nil {
+				r.Body = &timeoutBody{r.Body, tw}
+			}
+			f(tw, r)
+		}
+	}
+}
+
+// timeoutWriter drops writes once a 408 has been sent
+type timeoutWriter struct {
+	http.ResponseWriter
+	wrote    bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.timedOut {
+		return
+	}
+	tw.wrote = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wrote = true
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// timeoutBody sends a 408 if a read hits the deadline
+type timeoutBody struct {
+	io.ReadCloser
+	tw *timeoutWriter
+}
+
+func (tb *timeoutBody) Read(p []byte) (int, error) {
+	n, err := tb.ReadCloser.Read(p)
+	if err != nil && isTimeout(err) && !tb.tw.wrote && !tb.tw.timedOut {
+		http.Error(tb.tw.ResponseWriter, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+		tb.tw.timedOut = true
+	}
+	return n, err
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// TransformBody returns middleware that passes the body of responses whose
+// Content-Type starts with contentTypePrefix (e.g. "text/html") through fn,
+// e.g. to add a footer. Those bodies are buffered and sent with an updated
+// Content-Length once the handler returns. Other responses, e.g. binary or
+// compressed ones, are written through unchanged. If the handler doesn't
+// set a Content-Type it is sniffed from the first write.
+func TransformBody(fn func([]byte) []byte, contentTypePrefix string) Middleware {
+	return func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tw := &transformWriter{ResponseWriter: w, prefix: contentTypePrefix}
+			f(tw, r)
+			if !tw.decided {
+				tw.decide(nil)
+			}
+			if !tw.buffer {
+				return
+			}
+			body := fn(tw.body)
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(tw.status)
+			if r.Method != http.MethodHead {
+				w.Write(body)
+			}
+		}
+	}
+}
+
+// transformWriter buffers the body if the content type matches, once it
+// knows the content type
+type transformWriter struct {
+	http.ResponseWriter
+	prefix  string
+	status  int
+	decided bool
+	buffer  bool
+	body    []byte
+}
+
+// decide works out if the body is buffered, sniffing the content type from
+// b if it isn't set
+func (tw *transformWriter) decide(b []byte) {
+	tw.decided = true
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	ct := tw.Header().Get("Content-Type")
+	if ct == "" && b != nil {
+		ct = http.DetectContentType(b)
+		tw.Header().Set("Content-Type", ct)
+	}
+	// a compressed body can't be transformed
+	tw.buffer = strings.HasPrefix(ct, tw.prefix) && tw.Header().Get("Content-Encoding") == ""
+	if !tw.buffer {
+		tw.ResponseWriter.WriteHeader(tw.status)
+	}
+}
+
+func (tw *transformWriter) WriteHeader(code int) {
+	if tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *transformWriter) Write(b []byte) (int, error) {
+	if !tw.decided {
+		tw.decide(b)
+	}
+	if tw.buffer {
+		tw.body = append(tw.body, b...)
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *transformWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,242 @@
+package yar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveFrom(h http.Handler, remoteAddr, path string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", path, nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIPFilter(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(IPFilter(
+		[]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.7"},
+		[]string{"10.1.0.0/16", "2001:db8:bad::/48"},
+		nil,
+	))
+	rtr.HandleFunc("/", write("ok"))
+	tests := map[string]int{
+		"10.0.0.1:1234":          http.StatusOK,
+		"10.1.2.3:1234":          http.StatusForbidden, // denied within an allowed range
+		"192.0.2.7:1234":         http.StatusOK,
+		"192.0.2.8:1234":         http.StatusForbidden,
+		"8.8.8.8:1234":           http.StatusForbidden,
+		"[2001:db8::1]:1234":     http.StatusOK,
+		"[2001:db8:bad::1]:1234": http.StatusForbidden,
+		"[2001:db9::1]:1234":     http.StatusForbidden,
+		"[::ffff:10.0.0.1]:1234": http.StatusOK, // IPv4-mapped
+		"garbage":                http.StatusForbidden,
+	}
+	for addr, want := range tests {
+		if w := serveFrom(rtr, addr, "/"); w.Code != want {
+			t.Errorf("from %s: status %d, want %d", addr, w.Code, want)
+		}
+	}
+}
+
+func TestIPFilterEmptyAllow(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(IPFilter(nil, []string{"203.0.113.0/24", "2001:db8::/32"}, nil))
+	rtr.HandleFunc("/", write("ok"))
+	tests := map[string]int{
+		"198.51.100.1:1":  http.StatusOK,
+		"203.0.113.9:1":   http.StatusForbidden,
+		"[2001:db8::5]:1": http.StatusForbidden,
+		"[2001:db9::5]:1": http.StatusOK,
+	}
+	for addr, want := range tests {
+		if w := serveFrom(rtr, addr, "/"); w.Code != want {
+			t.Errorf("from %s: status %d, want %d", addr, w.Code, want)
+		}
+	}
+}
+
+func TestIPFilterPerRoute(t *testing.T) {
+	rtr := NewRouter()
+	admin := IPFilter([]string{"127.0.0.1"}, nil, nil)
+	rtr.HandleFunc("/admin", admin(write("admin")))
+	rtr.HandleFunc("/public", write("public"))
+	if w := serveFrom(rtr, "198.51.100.1:1", "/admin"); w.Code != http.StatusForbidden {
+		t.Errorf("/admin from outside = %d", w.Code)
+	}
+	if w := serveFrom(rtr, "127.0.0.1:1", "/admin"); w.Code != http.StatusOK {
+		t.Errorf("/admin from localhost = %d", w.Code)
+	}
+	if w := serveFrom(rtr, "198.51.100.1:1", "/public"); w.Code != http.StatusOK {
+		t.Errorf("/public = %d", w.Code)
+	}
+}
+
+func TestReadTimeoutSlowBody(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(ReadTimeout(50 * time.Millisecond))
+	rtr.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return
+		}
+		w.Write([]byte("done"))
+	})
+	srv := httptest.NewServer(rtr)
+	defer srv.Close()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// only 2 of the 10 bytes of the body are sent
+	fmt.Fprint(conn, "POST /upload HTTP/1.1\r\nHost: x\r\nContent-Length: 10\r\n\r\nab")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("slow body = %d, want 408", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/upload", "text/plain", strings.NewReader("0123456789"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("body sent in time = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/public", CacheControl(time.Hour, true)(write("")))
+	rtr.HandleFunc("/private", CacheControl(time.Minute, false)(write("")))
+	rtr.HandleFunc("/none", CacheControl(0, true)(write("")))
+	tests := map[string]string{
+		"/public":  "public, max-age=3600",
+		"/private": "private, max-age=60",
+		"/none":    "no-store",
+	}
+	for path, want := range tests {
+		w := serve(rtr, "GET", path)
+		if got := w.Header().Get("Cache-Control"); got != want {
+			t.Errorf("GET %s: Cache-Control %q, want %q", path, got, want)
+		}
+		expires := w.Header().Get("Expires")
+		if path == "/none" {
+			if expires != "0" {
+				t.Errorf("GET %s: Expires %q, want 0", path, expires)
+			}
+		} else if _, err := http.ParseTime(expires); err != nil {
+			t.Errorf("GET %s: Expires %q: %v", path, expires, err)
+		}
+	}
+}
+
+func TestMiddlewareOnNoMatch(t *testing.T) {
+	rtr := NewRouter()
+	var seen []string
+	rtr.Use(func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.Method+" "+r.URL.Path)
+			w.Header().Set("X-Middleware", "1")
+			f(w, r)
+		}
+	})
+	rtr.HandleMethod("GET", "/m", write(""))
+	if w := serve(rtr, "GET", "/nope"); w.Code != http.StatusNotFound || w.Header().Get("X-Middleware") != "1" {
+		t.Errorf("404 = %d, X-Middleware %q", w.Code, w.Header().Get("X-Middleware"))
+	}
+	rtr.HandleNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	if w := serve(rtr, "GET", "/nope"); w.Code != http.StatusTeapot || w.Header().Get("X-Middleware") != "1" {
+		t.Errorf("HandleNotFound = %d, X-Middleware %q", w.Code, w.Header().Get("X-Middleware"))
+	}
+	if w := serve(rtr, "POST", "/m"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("X-Middleware") != "1" {
+		t.Errorf("405 = %d, X-Middleware %q", w.Code, w.Header().Get("X-Middleware"))
+	}
+	if want := "GET /nope,GET /nope,POST /m"; strings.Join(seen, ",") != want {
+		t.Errorf("middleware saw %q, want %q", seen, want)
+	}
+}
+
+// buffered holds back the status and body until the handler returns
+type buffered struct {
+	http.ResponseWriter
+	code int
+	body []byte
+}
+
+func (b *buffered) WriteHeader(code int) { b.code = code }
+
+func (b *buffered) Write(p []byte) (int, error) {
+	b.body = append(b.body, p...)
+	return len(p), nil
+}
+
+func TestMiddlewareReplacesWriter(t *testing.T) {
+	rtr := NewRouter()
+	var info RequestInfo
+	var logged lines
+	rtr.OnRequest = func(i RequestInfo) { info = i }
+	rtr.Log = true
+	rtr.Logger = &logged
+	rtr.LogFormat = LogJSON
+	rtr.Use(func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			b := &buffered{ResponseWriter: w, code: http.StatusOK}
+			f(b, r)
+			w.WriteHeader(b.code)
+			w.Write([]byte(strings.ToUpper(string(b.body))))
+		}
+	})
+	rtr.HandleFunc("/x", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("tea"))
+	})
+	w := serve(rtr, "GET", "/x")
+	if w.Code != http.StatusTeapot || w.Body.String() != "TEA" {
+		t.Errorf("GET /x = %d %q, want the buffered response", w.Code, w.Body)
+	}
+	if info.Status != http.StatusTeapot {
+		t.Errorf("OnRequest status %d, want 418", info.Status)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], `"status":418`) {
+		t.Errorf("logged %q, want status 418", logged)
+	}
+}
+
+func TestTransformBody(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(TransformBody(func(b []byte) []byte {
+		return []byte(strings.Replace(string(b), "</body>", "<footer>f</footer></body>", 1))
+	}, "text/html"))
+	rtr.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "26")
+		w.Write([]byte("<html><body>hi</body></html>"))
+	})
+	rtr.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("</body>"))
+	})
+	w := serve(rtr, "GET", "/page")
+	want := "<html><body>hi<footer>f</footer></body></html>"
+	if w.Body.String() != want || w.Header().Get("Content-Length") != strconv.Itoa(len(want)) {
+		t.Errorf("HTML = %q with Content-Length %q, want %q", w.Body, w.Header().Get("Content-Length"), want)
+	}
+	if got := serve(rtr, "GET", "/bin").Body.String(); got != "</body>" {
+		t.Errorf("binary body = %q, want it unchanged", got)
+	}
+}
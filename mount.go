@@ -0,0 +1,98 @@
+package yar
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type mountKey struct{}
+
+type remainderKey struct{}
+
+// mount is what a request routed through Mount knows about its path
+type mount struct {
+	prefix   string // all the prefixes stripped, outermost first
+	original string // r.URL.Path before any were stripped
+}
+
+// Mount registers h for prefix and every path under it, like HandleSubtree,
+// but h gets the request with prefix stripped from the path, so an app can
+// be mounted without knowing where. "/api" passes "/api/users" on as
+// "/users" and "/api" as "/". StrippedPrefix and OriginalPath return what
+// was stripped, e.g. to build absolute links. Other routes under the prefix
+// are checked first.
+func (rtr *Router) Mount(prefix string, h http.Handler) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	re := regexp.MustCompile(mountRouteKey(prefix))
+	return rtr.insertRoute(&Route{Pattern: re, Func: func(w http.ResponseWriter, r *http.Request) {
+		r = stripMount(r, prefix)
+		setRouted(w, r)
+		h.ServeHTTP(w, r)
+	}, subtree: true})
+}
+
+// mountRouteKey returns the key a Mount of prefix, without a trailing "/", is
+// stored under
+func mountRouteKey(prefix string) string {
+	return "^" + regexp.QuoteMeta(prefix) + "(?:/.*)?$"
+}
+
+// stripMount returns a copy of r with prefix removed from the path
+func stripMount(r *http.Request, prefix string) *http.Request {
+	m := &mount{prefix: prefix, original: r.URL.Path}
+	if outer, ok := r.Context().Value(mountKey{}).(*mount); ok {
+		m.prefix, m.original = outer.prefix+prefix, outer.original
+	}
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	ctx := context.WithValue(r.Context(), mountKey{}, m)
+	r2 := r.WithContext(context.WithValue(ctx, remainderKey{}, rest))
+	u := *r.URL
+	u.Path = rest
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	if r.URL.RawPath != "" {
+		if rest := strings.TrimPrefix(r.URL.RawPath, prefix); rest != r.URL.RawPath {
+			u.RawPath = rest
+		}
+	}
+	r2.URL = &u
+	return r2
+}
+
+// StrippedPrefix returns the prefixes Mount stripped from the path of r,
+// with those of outer mounts first, or "" if it wasn't mounted
+func StrippedPrefix(r *http.Request) string {
+	if m, ok := r.Context().Value(mountKey{}).(*mount); ok {
+		return m.prefix
+	}
+	return ""
+}
+
+// OriginalPath returns the path of r before Mount stripped any prefixes
+// from it, which is r.URL.Path if it wasn't mounted
+func OriginalPath(r *http.Request) string {
+	if m, ok := r.Context().Value(mountKey{}).(*mount); ok {
+		return m.original
+	}
+	return r.URL.Path
+}
+
+// withRemainder returns r with the rest of the path after prefix stored for
+// PathRemainder
+func withRemainder(r *http.Request, prefix string) *http.Request {
+	rest := strings.TrimPrefix(r.URL.Path, prefix)
+	return r.WithContext(context.WithValue(r.Context(), remainderKey{}, rest))
+}
+
+// PathRemainder returns the rest of the path after the prefix of the
+// HandleSubtree or Mount route that matched r, e.g. "/a/b/c" for /proxy/a/b/c
+// under /proxy, or "" for /proxy itself. With nested routes it is the rest
+// after the innermost prefix.
+func PathRemainder(r *http.Request) string {
+	rest, _ := r.Context().Value(remainderKey{}).(string)
+	return rest
+}
@@ -0,0 +1,63 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMountNested(t *testing.T) {
+	app := NewRouter()
+	var got string
+	app.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path + " " + StrippedPrefix(r) + " " + OriginalPath(r)
+	})
+	app.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { got = "root" })
+	inner := NewRouter()
+	if err := inner.Mount("/v1/", app); err != nil {
+		t.Fatal(err)
+	}
+	rtr := NewRouter()
+	if err := rtr.Mount("/api", inner); err != nil {
+		t.Fatal(err)
+	}
+	serve(rtr, "GET", "/api/v1/users?x=1")
+	if want := "/users /api/v1 /api/v1/users"; got != want {
+		t.Errorf("nested mount got %q, want %q", got, want)
+	}
+	serve(rtr, "GET", "/api/v1")
+	if got != "root" {
+		t.Errorf("GET /api/v1 got %q, want the app's /", got)
+	}
+	if w := serve(rtr, "GET", "/apix"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /apix = %d, want 404", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/z", nil)
+	if StrippedPrefix(r) != "" || OriginalPath(r) != "/z" {
+		t.Errorf("outside a mount: StrippedPrefix %q, OriginalPath %q", StrippedPrefix(r), OriginalPath(r))
+	}
+}
+
+func TestPathRemainder(t *testing.T) {
+	rtr := NewRouter()
+	var got string
+	remainder := func(w http.ResponseWriter, r *http.Request) { got = PathRemainder(r) }
+	rtr.Mount("/proxy", http.HandlerFunc(remainder))
+	rtr.HandleSubtree("/docs/", remainder)
+	rtr.HandleFunc("/fixed", remainder)
+	tests := map[string]string{
+		"/proxy/a/b/c": "/a/b/c",
+		"/proxy":       "",
+		"/docs/x/y":    "/x/y",
+		"/docs":        "",
+		"/fixed":       "",
+	}
+	for path, want := range tests {
+		got = "unset"
+		serve(rtr, "GET", path)
+		if got != want {
+			t.Errorf("GET %s: PathRemainder %q, want %q", path, got, want)
+		}
+	}
+}
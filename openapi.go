@@ -0,0 +1,118 @@
+package yar
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// openAPIParam is a path parameter of an OpenAPI operation
+type openAPIParam struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// OpenAPISpec returns a minimal OpenAPI 3 document with a path item for
+// each fixed route and route with variables, as a scaffold to fill in. The
+// methods are those registered with HandleMethod, or GET for routes that take
+// any method. Variables become path parameters, <id:int> is an integer,
+// int(1..100) one with bounds and enum(a,b) an enum, other constraints are
+// left as strings. Other regexp and subtree routes can't be written as
+// OpenAPI paths and are left out, as are routes of hosts.
+func (rtr *Router) OpenAPISpec() ([]byte, error) {
+	paths := map[string]map[string]interface{}{}
+	for _, ri := range rtr.listRoutes("") {
+		var path string
+		var params []openAPIParam
+		switch {
+		case ri.Kind == "fixed":
+			path = ri.Pattern
+		case ri.Declared != "":
+			var ok bool
+			if path, params, ok = openAPIPath(ri.Declared); !ok {
+				continue
+			}
+		default:
+			continue
+		}
+		methods := ri.Methods
+		if len(methods) == 0 {
+			methods = []string{"GET"}
+		}
+		item := paths[path]
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		for _, m := range methods {
+			op := map[string]interface{}{
+				"responses": map[string]interface{}{
+					"default": map[string]string{"description": "response"},
+				},
+			}
+			if len(params) > 0 {
+				op["parameters"] = params
+			}
+			item[strings.ToLower(m)] = op
+		}
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "API", "version": "0.0.0"},
+		"paths":   paths,
+	}, "", "  ")
+}
+
+// openAPIPath converts a pattern with variables to an OpenAPI path, as in
+// /users/{id}, and its parameters. It returns false if the rest of the
+// pattern is a regexp rather than a literal path.
+func openAPIPath(pattern string) (string, []openAPIParam, bool) {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+	var b strings.Builder
+	params := []openAPIParam{}
+	last := 0
+	for _, m := range paramRegexp.FindAllStringSubmatchIndex(pattern, -1) {
+		literal := strings.ReplaceAll(pattern[last:m[0]], `\.`, ".")
+		if strings.ContainsAny(literal, `\^$*+?()[]{}|`) {
+			return "", nil, false
+		}
+		b.WriteString(literal)
+		name := strings.TrimSuffix(pattern[m[2]:m[3]], "?")
+		spec := ""
+		if m[4] >= 0 {
+			spec = pattern[m[4]:m[5]]
+		}
+		b.WriteString("{" + name + "}")
+		params = append(params, openAPIParam{Name: name, In: "path", Required: true, Schema: openAPISchema(spec)})
+		last = m[1]
+	}
+	literal := strings.ReplaceAll(pattern[last:], `\.`, ".")
+	if strings.ContainsAny(literal, `\^$*+?()[]{}|`) {
+		return "", nil, false
+	}
+	b.WriteString(literal)
+	return b.String(), params, true
+}
+
+// openAPISchema returns the schema of a variable with the constraint spec
+func openAPISchema(spec string) map[string]interface{} {
+	if spec == "int" {
+		return map[string]interface{}{"type": "integer"}
+	}
+	if strings.HasPrefix(spec, "int(") {
+		if r, err := parseIntRange(spec); err == nil {
+			return map[string]interface{}{"type": "integer", "minimum": r.min, "maximum": r.max}
+		}
+	}
+	if strings.HasPrefix(spec, "enum(") && strings.HasSuffix(spec, ")") {
+		values := []string{}
+		for _, v := range strings.Split(spec[len("enum("):len(spec)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,59 @@
+package yar
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/health", write(""))
+	rtr.HandleMethod("GET", "/users/<id:int>", write(""))
+	rtr.HandleMethod("DELETE", "/users/<id:int>", write(""))
+	rtr.HandleFunc("/files/<name>.<ext:enum(png,jpg)>$", write(""))
+	rtr.HandleFunc("/pages/<n:int(1..10)>", write(""))
+	rtr.HandleFunc("/re/.*", write(""))
+	rtr.HandleSubtree("/docs", write(""))
+	rtr.Host("x.com").HandleFunc("/host", write(""))
+	b, err := rtr.OpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []openAPIParam `json:"parameters"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	paths := []string{}
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if want := []string{"/files/{name}.{ext}", "/health", "/pages/{n}", "/users/{id}"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %q, want %q", paths, want)
+	}
+	if params := doc.Paths["/health"]["get"].Parameters; params != nil {
+		t.Errorf("/health has parameters %v", params)
+	}
+	users := doc.Paths["/users/{id}"]
+	if len(users) != 2 || users["delete"].Parameters[0].Schema["type"] != "integer" {
+		t.Errorf("/users/{id} = %+v", users)
+	}
+	file := doc.Paths["/files/{name}.{ext}"]["get"].Parameters
+	if len(file) != 2 || file[0].Name != "name" || !reflect.DeepEqual(file[1].Schema["enum"], []interface{}{"png", "jpg"}) {
+		t.Errorf("/files parameters = %+v", file)
+	}
+	page := doc.Paths["/pages/{n}"]["get"].Parameters[0].Schema
+	if page["minimum"] != 1.0 || page["maximum"] != 10.0 {
+		t.Errorf("/pages/{n} schema = %v", page)
+	}
+}
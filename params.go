@@ -0,0 +1,182 @@
+package yar
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+type paramsKey struct{}
+
+// params are the variables captured by a ParameterRoute
+type params struct {
+	names  []string
+	values []string
+}
+
+// withParams adds the variables to the request, before any it already has
+func withParams(r *http.Request, names, values []string) *http.Request {
+	if p := getParams(r); p != nil {
+		names = append(names[:len(names):len(names)], p.names...)
+		values = append(values[:len(values):len(values)], p.values...)
+	}
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, &params{names, values}))
+}
+
+func getParams(r *http.Request) *params {
+	p, _ := r.Context().Value(paramsKey{}).(*params)
+	return p
+}
+
+// Param returns the value captured for the variable name, or "" if there is
+// none
+func Param(r *http.Request, name string) string {
+	if p := getParams(r); p != nil {
+		for i, n := range p.names {
+			if n == name {
+				return p.values[i]
+			}
+		}
+	}
+	return ""
+}
+
+// ParamAll returns every value captured for the variable name, in the order
+// they appear in the pattern. There is more than one only if the Router
+// has DuplicateParams set.
+func ParamAll(r *http.Request, name string) []string {
+	var values []string
+	if p := getParams(r); p != nil {
+		for i, n := range p.names {
+			if n == name {
+				values = append(values, p.values[i])
+			}
+		}
+	}
+	return values
+}
+
+// ParamList returns the value captured for the variable name split at sep,
+// e.g. "1,2,3" for /items/<ids> gives 1, 2 and 3. Empty elements, as from
+// "1,,2," are left out, so an empty or missing value gives nil.
+func ParamList(r *http.Request, name, sep string) []string {
+	var list []string
+	for _, v := range strings.Split(Param(r, name), sep) {
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// ParamValues returns the variables captured from the URI in the order they
+// appear in the pattern, or nil if the request wasn't routed to a
+// ParameterRoute
+func ParamValues(r *http.Request) []string {
+	if p := getParams(r); p != nil {
+		return p.values
+	}
+	return nil
+}
+
+// paramTypes are the named constraints for variables, as in <id:int>
+var paramTypes = map[string]string{
+	"int": "([0-9]+)",
+}
+
+// paramRange is a variable declared with an int(min..max) constraint, index
+// is its position among the variables of the pattern
+type paramRange struct {
+	index    int
+	min, max int64
+}
+
+// in reports if value, which the capture has made sure is an integer, is in
+// the range
+func (pr paramRange) in(value string) bool {
+	n, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && n >= pr.min && n <= pr.max
+}
+
+// parseIntRange parses an int(min..max) constraint, the bounds are inclusive
+// and can be negative
+func parseIntRange(spec string) (paramRange, error) {
+	bounds, ok := strings.CutPrefix(spec, "int(")
+	if bounds, ok = strings.CutSuffix(bounds, ")"); !ok {
+		return paramRange{}, errors.New("Bad range: " + spec)
+	}
+	lo, hi, ok := strings.Cut(bounds, "..")
+	min, err := strconv.ParseInt(strings.TrimSpace(lo), 10, 64)
+	if !ok || err != nil {
+		return paramRange{}, errors.New("Bad range: " + spec)
+	}
+	max, err := strconv.ParseInt(strings.TrimSpace(hi), 10, 64)
+	if err != nil || max < min {
+		return paramRange{}, errors.New("Bad range: " + spec)
+	}
+	return paramRange{min: min, max: max}, nil
+}
+
+// rangeCapture returns the regexp to capture a variable constrained to r,
+// the range itself is checked once the route's regexp matches. The group is
+// named after the range, which doesn't change what it matches but puts the
+// range in the route's key, so routes differing only in their ranges don't
+// collide.
+func rangeCapture(r paramRange) string {
+	digits := "[0-9]+"
+	if r.min < 0 {
+		digits = "-?[0-9]+"
+	}
+	return "(?P<int_" + rangeBound(r.min) + "_" + rangeBound(r.max) + ">" + digits + ")"
+}
+
+// rangeBound writes n for the name of a group, which can't contain "-"
+func rangeBound(n int64) string {
+	if n < 0 {
+		return "m" + strconv.FormatInt(-n, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// constraintCapture returns the regexp to capture a variable declared with a
+// constraint, as in <name:constraint>. The constraint is a name from
+// paramTypes, enum(a,b,c) listing the allowed values or a regexp the value
+// must match. Ranges, as in int(1..100), are handled by expandParams.
+func constraintCapture(spec string) (string, error) {
+	if capture, ok := paramTypes[spec]; ok {
+		return capture, nil
+	}
+	if strings.HasPrefix(spec, "enum(") && strings.HasSuffix(spec, ")") {
+		values := []string{}
+		for _, v := range strings.Split(spec[len("enum("):len(spec)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, regexp.QuoteMeta(v))
+			}
+		}
+		if len(values) == 0 {
+			return "", errors.New("Empty enum: " + spec)
+		}
+		return "(" + strings.Join(values, "|") + ")", nil
+	}
+	re, err := syntax.Parse(spec, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	return "(" + nonCapturing(re).String() + ")", nil
+}
+
+// nonCapturing removes the capture groups from re so they don't shift the
+// position of the variables
+func nonCapturing(re *syntax.Regexp) *syntax.Regexp {
+	for i, sub := range re.Sub {
+		re.Sub[i] = nonCapturing(sub)
+	}
+	if re.Op == syntax.OpCapture {
+		return re.Sub[0]
+	}
+	return re
+}
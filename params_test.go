@@ -0,0 +1,322 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func BenchmarkParameterRoute(b *testing.B) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/users/<id>/posts/<post>$", func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest("GET", "/users/42/posts/7?sort=asc", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// the variables are added to the query, start from the original one
+		r.URL.RawQuery = "sort=asc"
+		rtr.ServeHTTP(w, r)
+	}
+}
+
+// captured serves path and returns r as the handler of pattern got it
+func captured(t *testing.T, rtr *Router, pattern, path string) *http.Request {
+	t.Helper()
+	var got *http.Request
+	if err := rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) { got = r }); err != nil {
+		t.Fatal(err)
+	}
+	serve(rtr, "GET", path)
+	if got == nil {
+		t.Fatalf("%s didn't match %s", pattern, path)
+	}
+	return got
+}
+
+func TestParamValues(t *testing.T) {
+	r := captured(t, NewRouter(), "/u/<b>/<a>$", "/u/first/second")
+	if got := ParamValues(r); !reflect.DeepEqual(got, []string{"first", "second"}) {
+		t.Errorf("ParamValues = %q, want pattern order", got)
+	}
+	if Param(r, "b") != "first" || Param(r, "a") != "second" {
+		t.Errorf("Param b = %q, a = %q", Param(r, "b"), Param(r, "a"))
+	}
+
+	r = captured(t, NewRouter(), "/fixed", "/fixed")
+	if got := ParamValues(r); got != nil {
+		t.Errorf("ParamValues for a fixed route = %q, want nil", got)
+	}
+}
+
+func TestInvalidVariableName(t *testing.T) {
+	rtr := NewRouter()
+	for _, pattern := range []string{"/a/<>", "/a/<1abc>", "/a/<:int>"} {
+		if err := rtr.HandleFunc(pattern, write("")); err == nil {
+			t.Errorf("%s registered, want an invalid variable name error", pattern)
+		}
+	}
+	for _, pattern := range []string{"/b/<_ok1>", "/c/<Id:int>"} {
+		if err := rtr.HandleFunc(pattern, write("")); err != nil {
+			t.Errorf("%s: %v", pattern, err)
+		}
+	}
+}
+
+func TestEnumParam(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleFunc("/s/<status:enum(active,inactive,pending)>$", write("ok")); err != nil {
+		t.Fatal(err)
+	}
+	r := captured(t, rtr, "/x/<status:enum(active,inactive)>/<id>$", "/x/inactive/9")
+	if got := ParamValues(r); !reflect.DeepEqual(got, []string{"inactive", "9"}) {
+		t.Errorf("ParamValues = %q", got)
+	}
+	tests := map[string]int{
+		"/s/active":  http.StatusOK,
+		"/s/pending": http.StatusOK,
+		"/s/bogus":   http.StatusNotFound,
+		"/s/activeX": http.StatusNotFound,
+	}
+	for path, want := range tests {
+		if w := serve(rtr, "GET", path); w.Code != want {
+			t.Errorf("GET %s = %d, want %d", path, w.Code, want)
+		}
+	}
+	if err := rtr.HandleFunc("/e/<x:enum()>", write("")); err == nil {
+		t.Error("enum() registered, want an error")
+	}
+}
+
+func TestQueryOrder(t *testing.T) {
+	r := captured(t, NewRouter(), "/u/<b>/<a>$", "/u/x%20y/2?z=1&a=3&z=2")
+	if want := "b=x+y&a=2&z=1&a=3&z=2"; r.URL.RawQuery != want {
+		t.Errorf("RawQuery = %q, want %q", r.URL.RawQuery, want)
+	}
+	if got := r.URL.Query()["a"]; !reflect.DeepEqual(got, []string{"2", "3"}) {
+		t.Errorf("a = %q, want the captured value first", got)
+	}
+	r = captured(t, NewRouter(), "/u/<b>/<a>$", "/u/1/2")
+	if r.URL.RawQuery != "b=1&a=2" {
+		t.Errorf("RawQuery without a query = %q", r.URL.RawQuery)
+	}
+}
+
+func TestParamsInOneSegment(t *testing.T) {
+	r := captured(t, NewRouter(), "/img/<w>x<h>/<file>$", "/img/100x200/cat.png")
+	if got := ParamValues(r); !reflect.DeepEqual(got, []string{"100", "200", "cat.png"}) {
+		t.Errorf("ParamValues = %q", got)
+	}
+
+	rtr := NewRouter()
+	rtr.DuplicateParams = true
+	r = captured(t, rtr, "/img/<size>x<size>/<file>$", "/img/100x200/cat.png")
+	if got := ParamAll(r, "size"); !reflect.DeepEqual(got, []string{"100", "200"}) {
+		t.Errorf("ParamAll size = %q", got)
+	}
+
+	rtr = NewRouter()
+	rtr.HandleFunc("/img/<w>x<h>$", write(""))
+	if w := serve(rtr, "GET", "/img/100x200/x"); w.Code != http.StatusNotFound {
+		t.Errorf("a variable matched across segments, got %d", w.Code)
+	}
+}
+
+func TestParamsWithLiteralDot(t *testing.T) {
+	rtr := NewRouter()
+	var ext, name string
+	rtr.HandleFunc("/data.<ext:json|xml>$", func(w http.ResponseWriter, r *http.Request) { ext = Param(r, "ext") })
+	rtr.HandleFunc("/files/<name>.<type>$", func(w http.ResponseWriter, r *http.Request) {
+		name, ext = Param(r, "name"), Param(r, "type")
+	})
+	for _, want := range []string{"json", "xml"} {
+		if w := serve(rtr, "GET", "/data."+want); w.Code != http.StatusOK || ext != want {
+			t.Errorf("GET /data.%s = %d with ext %q", want, w.Code, ext)
+		}
+	}
+	for _, path := range []string{"/dataxjson", "/data.yaml"} {
+		if w := serve(rtr, "GET", path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+	if serve(rtr, "GET", "/files/cat.png"); name != "cat" || ext != "png" {
+		t.Errorf("GET /files/cat.png: name %q, type %q", name, ext)
+	}
+}
+
+func TestOptionalParam(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a/<b?>/c$", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[" + Param(r, "b") + "]"))
+	})
+	rtr.HandleFunc("/t/<x?>$", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("t[" + Param(r, "x") + "]"))
+	})
+	rtr.HandleFunc("/n/<x>/c$", write(""))
+	tests := map[string]string{
+		"/a//c":  "[]",
+		"/a/z/c": "[z]",
+		"/t/":    "t[]",
+		"/t/v":   "t[v]",
+		"/n//c":  "",
+		"/t":     "",
+	}
+	for path, want := range tests {
+		w := serve(rtr, "GET", path)
+		if want == "" && w.Code != http.StatusNotFound || want != "" && w.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want %q", path, w.Code, w.Body, want)
+		}
+	}
+	if err := rtr.HandleFunc("/q/<x?:int>", write("")); err == nil {
+		t.Error("optional variable with a constraint registered")
+	}
+}
+
+func TestQueryNotMatched(t *testing.T) {
+	r := captured(t, NewRouter(), "/u/<id>$", "/u/5?id=/u/6&x=%2F")
+	if got := ParamValues(r); !reflect.DeepEqual(got, []string{"5"}) {
+		t.Errorf("ParamValues = %q, want only the path variable", got)
+	}
+	if Param(r, "id") != "5" {
+		t.Errorf("Param id = %q", Param(r, "id"))
+	}
+
+	rtr := NewRouter()
+	rtr.HandleFunc("/exact", write("exact"))
+	for _, escaped := range []bool{false, true} {
+		rtr.UseEscapedPath = escaped
+		if got := serve(rtr, "GET", "/exact?a=b"); got.Body.String() != "exact" {
+			t.Errorf("UseEscapedPath %v: fixed route with a query = %d", escaped, got.Code)
+		}
+	}
+}
+
+func TestParamList(t *testing.T) {
+	rtr := NewRouter()
+	var got []string
+	rtr.HandleFunc("/items/<ids?>$", func(w http.ResponseWriter, r *http.Request) {
+		got = ParamList(r, "ids", ",")
+	})
+	tests := map[string][]string{
+		"/items/1,2,3":   {"1", "2", "3"},
+		"/items/1,,2,3,": {"1", "2", "3"},
+		"/items/,":       nil,
+		"/items/":        nil,
+		"/items/a%2Cb":   {"a", "b"},
+	}
+	for path, want := range tests {
+		got = []string{"unset"}
+		serve(rtr, "GET", path)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GET %s: ParamList = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestHandleWithClass(t *testing.T) {
+	rtr := NewRouter()
+	var got string
+	err := rtr.HandleWithClass("/w/<word>/<n:int>$", "[a-z]+", func(w http.ResponseWriter, r *http.Request) {
+		got = Param(r, "word") + " " + Param(r, "n")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(rtr, "GET", "/w/abc/4"); w.Code != http.StatusOK || got != "abc 4" {
+		t.Errorf("GET /w/abc/4 = %d with %q", w.Code, got)
+	}
+	for _, path := range []string{"/w/Abc/4", "/w/ab1/4", "/w/abc/x"} {
+		if w := serve(rtr, "GET", path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+	if err := rtr.HandleWithClass("/x/<a>", "[a-", write("")); err == nil {
+		t.Error("invalid class registered")
+	}
+}
+
+func TestDuplicateParams(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleFunc("/<id>/to/<id>$", write("")); err == nil {
+		t.Error("duplicate variable registered without DuplicateParams")
+	}
+	rtr.DuplicateParams = true
+	r := captured(t, rtr, "/<id>/to/<id>$", "/a/to/b")
+	if Param(r, "id") != "a" {
+		t.Errorf("Param id = %q, want the first value", Param(r, "id"))
+	}
+	if got := ParamAll(r, "id"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("ParamAll id = %q", got)
+	}
+	if got := ParamAll(r, "missing"); got != nil {
+		t.Errorf("ParamAll of a missing variable = %q", got)
+	}
+}
+
+func TestIntRange(t *testing.T) {
+	rtr := NewRouter()
+	page := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("page " + Param(r, "n"))) }
+	if err := rtr.HandleFunc("/page/<n:int(1..100)>", page); err != nil {
+		t.Fatal(err)
+	}
+	err := rtr.HandleFunc("/t/<c:int(-10..10)>/<x>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Param(r, "c") + " " + Param(r, "x")))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtr.HandleSubtree("/page", write("fallback"))
+	tests := map[string]string{
+		"/page/1":                    "page 1",
+		"/page/100":                  "page 100",
+		"/page/007":                  "page 007",
+		"/page/0":                    "fallback",
+		"/page/101":                  "fallback",
+		"/page/-1":                   "fallback",
+		"/page/99999999999999999999": "fallback",
+		"/t/-3/a":                    "-3 a",
+		"/t/-10/a":                   "-10 a",
+		"/t/10/a":                    "10 a",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+	if w := serve(rtr, "GET", "/t/-11/a"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /t/-11/a = %d, want 404", w.Code)
+	}
+	for _, bad := range []string{"/b/<n:int(5..1)>", "/b/<n:int(1-5)>", "/b/<n:int(a..5)>"} {
+		if err := rtr.HandleFunc(bad, write("")); err == nil {
+			t.Errorf("%s registered", bad)
+		}
+	}
+}
+
+func TestDisjointIntRanges(t *testing.T) {
+	rtr := NewRouter()
+	for pattern, body := range map[string]string{
+		"/p/<n:int(1..10)>":  "low",
+		"/p/<n:int(11..20)>": "high",
+		"/p/<n:int(-5..-1)>": "negative",
+		"/p/<n:int>":         "other",
+	} {
+		if err := rtr.HandleFunc(pattern, write(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := map[string]string{
+		"/p/5":  "low",
+		"/p/15": "high",
+		"/p/-3": "negative",
+		"/p/25": "other",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+	if err := rtr.HandleFunc("/p/<m:int(1..10)>", write("")); err == nil {
+		t.Error("the same range registered twice")
+	}
+}
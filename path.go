@@ -0,0 +1,48 @@
+package yar
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PathBuilder builds a pattern for HandleFunc one segment at a time, e.g.
+// Path().Segment("users").Param("id", "int").String() is "/users/<id:int>"
+type PathBuilder struct {
+	segments []string
+	quoted   bool // a segment had regexp characters
+}
+
+// Path returns an empty PathBuilder
+func Path() *PathBuilder {
+	return &PathBuilder{}
+}
+
+// Segment adds a literal segment, regexp characters in it are quoted
+func (pb *PathBuilder) Segment(s string) *PathBuilder {
+	quoted := regexp.QuoteMeta(s)
+	pb.quoted = pb.quoted || quoted != s
+	pb.segments = append(pb.segments, quoted)
+	return pb
+}
+
+// Param adds a variable segment, constraint is as in <name:constraint> and
+// can be "" for any value
+func (pb *PathBuilder) Param(name, constraint string) *PathBuilder {
+	if constraint != "" {
+		name += ":" + constraint
+	}
+	pb.segments = append(pb.segments, "<"+name+">")
+	return pb
+}
+
+// String returns the pattern. If a segment had regexp characters the pattern
+// is a regexp, so it is anchored to match the whole path like a fixed route
+// would, e.g. Segment("v1.0") gives "^/v1\.0$". Without variables that needs
+// CheckRegexp, which NewRouter sets.
+func (pb *PathBuilder) String() string {
+	pattern := "/" + strings.Join(pb.segments, "/")
+	if pb.quoted {
+		return "^" + pattern + "$"
+	}
+	return pattern
+}
@@ -0,0 +1,62 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPathBuilder(t *testing.T) {
+	tests := []struct {
+		pb   *PathBuilder
+		want string
+	}{
+		{Path().Segment("users").Param("id", "int"), "/users/<id:int>"},
+		{Path().Segment("files").Param("name", ""), "/files/<name>"},
+		{Path().Segment("v1.0"), `^/v1\.0$`},
+		{Path().Segment("v1.0").Param("id", ""), `^/v1\.0/<id>$`},
+	}
+	for _, tt := range tests {
+		if got := tt.pb.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestPathBuilderRoundTrip(t *testing.T) {
+	rtr := NewRouter()
+	routes := map[string]*PathBuilder{
+		"version": Path().Segment("v1.0"),
+		"item":    Path().Segment("v1.0").Segment("items").Param("id", "int"),
+		"user":    Path().Segment("users").Param("id", "int"),
+		"plus":    Path().Segment("a+b"),
+	}
+	for body, pb := range routes {
+		if err := rtr.HandleFunc(pb.String(), write(body)); err != nil {
+			t.Fatalf("%s: %v", pb, err)
+		}
+	}
+	tests := []struct {
+		path string
+		want string // "" for a 404
+	}{
+		{"/v1.0", "version"},
+		{"/v1x0", ""},
+		{"/other/v1.0/zzz", ""},
+		{"/v1.0/items/7", "item"},
+		{"/other/v1.0/items/7", ""},
+		{"/v1.0/items/x", ""},
+		{"/users/3", "user"},
+		{"/a+b", "plus"},
+		{"/aab", ""},
+	}
+	for _, tt := range tests {
+		w := serve(rtr, "GET", tt.path)
+		if tt.want == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET %s = %d %q, want 404", tt.path, w.Code, w.Body)
+			}
+		} else if w.Body.String() != tt.want {
+			t.Errorf("GET %s = %d %q, want %q", tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+}
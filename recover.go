@@ -0,0 +1,81 @@
+package yar
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// maxPanicStack is how much of the stack of a recovered panic is kept
+const maxPanicStack = 4 << 10
+
+// RequestInfo describes a handled request, it is passed to OnRequest
+type RequestInfo struct {
+	Method   string
+	Path     string
+	Pattern  string // key of the route that matched, "" if none did
+	Status   int
+	Duration time.Duration
+	// the value a handler panicked with if Recover caught a panic, and the
+	// start of the stack at that point
+	Panic interface{}
+	Stack []byte
+	// the first error writing the response body, e.g. because the client
+	// disconnected, so it can be told apart from a server error
+	WriteError error
+}
+
+// recovered is a panic caught while serving a request
+type recovered struct {
+	value interface{}
+	stack []byte
+}
+
+// callRecover calls f and recovers a panic, sending a 500 if nothing has been
+// written. It returns the panic or nil if there wasn't one.
+func (rtr *Router) callRecover(f http.HandlerFunc, rw *responseWriter, r *http.Request) (p *recovered) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		stack := debug.Stack()
+		if len(stack) > maxPanicStack {
+			stack = stack[:maxPanicStack]
+		}
+		p = &recovered{v, stack}
+		if rtr.Log {
+			rtr.logln("panic: " + sanitize(r.URL.Path) + ": " + sanitize(fmt.Sprint(v)))
+		}
+		if rtr.PanicHandler != nil {
+			rtr.PanicHandler(rw, r, v)
+		}
+		if !rw.wroteHeader {
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}()
+	f(rw, r)
+	return nil
+}
+
+func newRequestInfo(r *http.Request, path, pattern string, rw *responseWriter, p *recovered) RequestInfo {
+	info := RequestInfo{
+		Method:     r.Method,
+		Path:       path,
+		Pattern:    pattern,
+		Status:     rw.status,
+		Duration:   time.Since(rw.start),
+		WriteError: rw.writeErr,
+	}
+	if info.Status == 0 {
+		info.Status = http.StatusOK
+	}
+	if p != nil {
+		info.Panic, info.Stack = p.value, p.stack
+	}
+	return info
+}
@@ -0,0 +1,48 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	for _, custom := range []bool{false, true} {
+		rtr := NewRouter()
+		rtr.Recover = true
+		var caught interface{}
+		if custom {
+			rtr.PanicHandler = func(w http.ResponseWriter, r *http.Request, v interface{}) {
+				caught = v
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("oops"))
+			}
+		}
+		var info RequestInfo
+		rtr.OnRequest = func(i RequestInfo) { info = i }
+		rtr.HandleFunc("/p/<id>", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+		w := serve(rtr, "GET", "/p/1")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("PanicHandler %v: status %d, want 500", custom, w.Code)
+		}
+		if custom && (w.Body.String() != "oops" || caught != "boom") {
+			t.Errorf("PanicHandler got %v and wrote %q", caught, w.Body)
+		}
+		if info.Status != http.StatusInternalServerError || info.Panic != "boom" || len(info.Stack) == 0 || info.Pattern == "" {
+			t.Errorf("PanicHandler %v: OnRequest got %+v", custom, info)
+		}
+		if len(info.Stack) > maxPanicStack {
+			t.Errorf("stack of %d bytes, want at most %d", len(info.Stack), maxPanicStack)
+		}
+	}
+}
+
+func TestRecoverOff(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/p", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("recovered %v, want the panic to reach the caller", v)
+		}
+	}()
+	serve(rtr, "GET", "/p")
+}
@@ -0,0 +1,64 @@
+package yar
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var handlerFuncType = reflect.TypeOf(http.HandlerFunc(nil))
+
+// RegisterStruct registers the func fields of the struct v (or a pointer to
+// one) that have a route tag, as in
+//
+//	type Users struct {
+//		Get    http.HandlerFunc `route:"GET /users/<id>"`
+//		Update http.HandlerFunc `route:"PUT /users/<id>"`
+//		List   http.HandlerFunc `route:"/users"`
+//	}
+//
+// The tag is a pattern, optionally after a method to register it with
+// HandleMethod. Go methods can't have tags, so a controller sets the fields
+// to its methods. It returns an error if a tagged field isn't of type
+// http.HandlerFunc or func(http.ResponseWriter, *http.Request), is nil or
+// can't be registered.
+func RegisterStruct(rtr *Router, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("Not a struct: " + rv.Kind().String())
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("route")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() || !field.Type.ConvertibleTo(handlerFuncType) {
+			return errors.New("Field " + field.Name + " must be an exported http.HandlerFunc")
+		}
+		fv := rv.Field(i)
+		if fv.IsNil() {
+			return errors.New("Field " + field.Name + " is nil")
+		}
+		f := fv.Convert(handlerFuncType).Interface().(http.HandlerFunc)
+		method, pattern := "", strings.TrimSpace(tag)
+		if i := strings.IndexByte(pattern, ' '); i > 0 {
+			method, pattern = pattern[:i], strings.TrimSpace(pattern[i+1:])
+		}
+		var err error
+		if method != "" {
+			err = rtr.HandleMethod(method, pattern, f)
+		} else {
+			err = rtr.HandleFunc(pattern, f)
+		}
+		if err != nil {
+			return errors.New("Field " + field.Name + ": " + err.Error())
+		}
+	}
+	return nil
+}
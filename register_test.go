@@ -0,0 +1,65 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+)
+
+type usersController struct {
+	Get    http.HandlerFunc                         `route:"GET /users/<id>$"`
+	Update func(http.ResponseWriter, *http.Request) `route:"PUT /users/<id>$"`
+	List   http.HandlerFunc                         `route:"/users"`
+	// no route tag, so it isn't registered
+	Other http.HandlerFunc
+}
+
+func TestRegisterStruct(t *testing.T) {
+	rtr := NewRouter()
+	c := &usersController{
+		Get: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("get " + Param(r, "id")))
+		},
+		Update: write("put"),
+		List:   write("list"),
+	}
+	if err := RegisterStruct(rtr, c); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ method, path, want string }{
+		{"GET", "/users/3", "get 3"},
+		{"PUT", "/users/3", "put"},
+		{"GET", "/users", "list"},
+	}
+	for _, tt := range tests {
+		if got := serve(rtr, tt.method, tt.path).Body.String(); got != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+	if w := serve(rtr, "DELETE", "/users/3"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /users/3 = %d, want 405", w.Code)
+	}
+}
+
+func TestRegisterStructErrors(t *testing.T) {
+	type badSignature struct {
+		F func(http.ResponseWriter) `route:"/x"`
+	}
+	type notFunc struct {
+		X int `route:"/x"`
+	}
+	type unexported struct {
+		f http.HandlerFunc `route:"/x"`
+	}
+	tests := map[string]interface{}{
+		"bad signature": badSignature{F: func(http.ResponseWriter) {}},
+		"not a func":    notFunc{},
+		"unexported":    unexported{f: write("")},
+		"nil field":     &usersController{List: write("")},
+		"not a struct":  3,
+	}
+	for name, v := range tests {
+		if err := RegisterStruct(NewRouter(), v); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package yar
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClientIP returns the IP of the client that sent r. The X-Forwarded-For and
+// X-Real-IP headers are only used when the RemoteAddr is one of the
+// trustedProxies (IPs or CIDRs), in which case the X-Forwarded-For hops are
+// read from the right skipping the trusted ones. Otherwise it is the host of
+// the RemoteAddr. Entries of trustedProxies that can't be parsed are
+// skipped, IPFilter parses them once and panics on a bad one instead.
+func ClientIP(r *http.Request, trustedProxies []string) string {
+	trusted, _ := parseNets(trustedProxies)
+	return clientIP(r, trusted)
+}
+
+// clientIP is ClientIP with the trusted proxies already parsed
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if ip := net.ParseIP(remote); ip == nil || !containsIP(trusted, ip) {
+		return remote
+	}
+	hops := []string{}
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !containsIP(trusted, ip) {
+			return client
+		}
+	}
+	if client != "" {
+		return client
+	}
+	if ip := parseHop(r.Header.Get("X-Real-IP")); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// parseHop parses an IP from a forwarding header, which may include a port
+func parseHop(hop string) net.IP {
+	if h, _, err := net.SplitHostPort(hop); err == nil {
+		hop = h
+	}
+	return net.ParseIP(strings.TrimSpace(hop))
+}
+
+// BearerToken returns the token from an "Authorization: Bearer <token>"
+// header, the scheme is matched case-insensitively. It returns false if the
+// header is missing, uses another scheme or has no token.
+func BearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	return token, token != ""
+}
+
+// IsHTTP2 reports if r was made over HTTP/2 or later, going by r.ProtoMajor
+func IsHTTP2(r *http.Request) bool {
+	return r.ProtoMajor >= 2
+}
+
+// IsChunked reports if the body of r is sent with chunked transfer encoding,
+// e.g. a streamed upload with no Content-Length. The router never reads
+// request bodies itself, so the handler can stream it.
+func IsChunked(r *http.Request) bool {
+	for _, te := range r.TransferEncoding {
+		if strings.EqualFold(te, "chunked") {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBody reports if r has a non-empty body. It goes by the Content-Length
+// when there is one, otherwise (e.g. a chunked upload) it reads the first
+// byte and puts it back, so the handler still gets the whole body.
+func HasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+	if r.ContentLength > 0 {
+		return true
+	}
+	var b [1]byte
+	n, err := io.ReadFull(r.Body, b[:])
+	if n == 0 {
+		if err != io.EOF {
+			// keep the read error for the handler
+			r.Body = errorBody{r.Body, err}
+		}
+		return false
+	}
+	r.Body = peekedBody{io.MultiReader(bytes.NewReader(b[:n]), r.Body), r.Body}
+	return true
+}
+
+// peekedBody is a request body with bytes read from it put back in front
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errorBody is a request body reading which failed with err
+type errorBody struct {
+	io.Closer
+	err error
+}
+
+func (b errorBody) Read([]byte) (int, error) {
+	return 0, b.err
+}
+
+// DeadlineRemaining returns how long is left until the deadline of the
+// request context, e.g. one set by a timeout middleware, so a handler can
+// bound its own calls to it. It returns false if there is no deadline, and
+// a negative duration if it has passed.
+func DeadlineRemaining(r *http.Request) (time.Duration, bool) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// PreferredCharset returns the charset of supported the Accept-Charset of r
+// gives the highest q-value, ties going to the one listed first in
+// supported. A "*" in the header covers the charsets not named in it.
+// The first of supported is the default, returned if there is no
+// Accept-Charset or none of supported is acceptable.
+func PreferredCharset(r *http.Request, supported ...string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+	header := r.Header.Get("Accept-Charset")
+	if strings.TrimSpace(header) == "" {
+		return supported[0]
+	}
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		weights[name] = q
+	}
+	best, bestQ := supported[0], 0.0
+	for _, charset := range supported {
+		q, ok := weights[strings.ToLower(charset)]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = charset, q
+		}
+	}
+	return best
+}
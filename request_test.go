@@ -0,0 +1,213 @@
+package yar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "fd00::/8", "not-an-ip"}
+	tests := []struct {
+		name, remote, forwarded, realIP, want string
+	}{
+		{"untrusted remote ignores headers", "203.0.113.7:1234", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"trusted remote uses forwarded", "10.1.2.3:80", "198.51.100.1", "", "198.51.100.1"},
+		{"rightmost untrusted hop", "10.1.2.3:80", "198.51.100.9, 198.51.100.1, 10.0.0.5", "", "198.51.100.1"},
+		{"spoofed leftmost hop ignored", "10.1.2.3:80", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"all hops trusted", "10.1.2.3:80", "10.0.0.9, 10.0.0.5", "", "10.0.0.9"},
+		{"hop with port", "10.1.2.3:80", "198.51.100.1:5555", "", "198.51.100.1"},
+		{"real ip fallback", "10.1.2.3:80", "", "198.51.100.3", "198.51.100.3"},
+		{"no headers", "10.1.2.3:80", "", "", "10.1.2.3"},
+		{"ipv6 untrusted", "[2001:db8::1]:443", "198.51.100.1", "", "2001:db8::1"},
+		{"ipv6 trusted proxy", "[fd00::1]:443", "2001:db8::7, fd00::2", "", "2001:db8::7"},
+		{"ipv6 hop with port", "[fd00::1]:443", "[2001:db8::8]:1234", "", "2001:db8::8"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := ClientIP(r, trusted); got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientIPSeveralHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.1.2.3:80"
+	r.Header.Add("X-Forwarded-For", "198.51.100.1")
+	r.Header.Add("X-Forwarded-For", "198.51.100.2, 10.0.0.1")
+	if got := ClientIP(r, []string{"10.0.0.0/8"}); got != "198.51.100.2" {
+		t.Errorf("ClientIP = %q, want 198.51.100.2", got)
+	}
+}
+
+func TestIPFilterTrustedProxies(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(IPFilter([]string{"198.51.100.0/24"}, nil, []string{"10.0.0.0/8"}))
+	rtr.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		remote, forwarded string
+		want              int
+	}{
+		{"10.1.2.3:80", "198.51.100.1", http.StatusOK},
+		{"10.1.2.3:80", "198.51.100.1, 203.0.113.1", http.StatusForbidden},
+		{"203.0.113.1:80", "198.51.100.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		r.Header.Set("X-Forwarded-For", tt.forwarded)
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("from %s via %s: status %d, want %d", tt.forwarded, tt.remote, w.Code, tt.want)
+		}
+	}
+}
+
+func TestIPFilterBadProxyPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("IPFilter accepted an invalid trusted proxy")
+		}
+	}()
+	IPFilter(nil, nil, []string{"10.0.0.0/8", "bogus"})
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := map[string]string{
+		"Bearer abc.def": "abc.def",
+		"bEaReR abc.def": "abc.def",
+		"":               "",
+		"Basic xyz":      "",
+		"Bearer ":        "",
+		"Bearer":         "",
+	}
+	for header, want := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		if got, ok := BearerToken(r); got != want || ok != (want != "") {
+			t.Errorf("Authorization %q: BearerToken = %q, %v, want %q", header, got, ok, want)
+		}
+	}
+}
+
+func TestChunkedStream(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Use(ReadTimeout(5 * time.Second))
+	rtr.CountBytes = true
+	firstRead := make(chan string)
+	var chunked bool
+	rtr.HandleFunc("/up", func(w http.ResponseWriter, r *http.Request) {
+		Parse(r)
+		chunked = IsChunked(r)
+		first := make([]byte, 3)
+		if _, err := io.ReadFull(r.Body, first); err != nil {
+			t.Error(err)
+		}
+		// the client sends the rest only once this has been read
+		firstRead <- string(first)
+		rest, _ := io.ReadAll(r.Body)
+		w.Write(append(first, rest...))
+	})
+	srv := httptest.NewServer(rtr)
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("abc"))
+		if got := <-firstRead; got != "abc" {
+			t.Errorf("handler read %q first", got)
+		}
+		pw.Write([]byte("def"))
+		pw.Close()
+	}()
+	resp, err := http.Post(srv.URL+"/up", "application/octet-stream", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if !chunked || string(b) != "abcdef" {
+		t.Errorf("chunked %v, handler read %q", chunked, b)
+	}
+	if IsChunked(httptest.NewRequest("POST", "/up", strings.NewReader("x"))) {
+		t.Error("IsChunked for a body with a Content-Length")
+	}
+}
+
+func TestDeadlineRemaining(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if d, ok := DeadlineRemaining(r); ok {
+		t.Errorf("DeadlineRemaining without a deadline = %v, true", d)
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+	if d, ok := DeadlineRemaining(r.WithContext(ctx)); !ok || d <= 50*time.Second || d > time.Minute {
+		t.Errorf("DeadlineRemaining = %v, %v, want about a minute", d, ok)
+	}
+	past, cancelPast := context.WithDeadline(r.Context(), time.Now().Add(-time.Second))
+	defer cancelPast()
+	if d, ok := DeadlineRemaining(r.WithContext(past)); !ok || d > 0 {
+		t.Errorf("DeadlineRemaining after the deadline = %v, %v", d, ok)
+	}
+}
+
+func TestHasBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   io.Reader
+		length int64
+		want   string
+	}{
+		{"no body", nil, 0, ""},
+		{"content length", strings.NewReader("xyz"), 3, "xyz"},
+		{"chunked", io.MultiReader(strings.NewReader("chunk")), -1, "chunk"},
+		{"empty chunked", io.MultiReader(), -1, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", tt.body)
+		r.ContentLength = tt.length
+		if got := HasBody(r); got != (tt.want != "") {
+			t.Errorf("%s: HasBody = %v", tt.name, got)
+		}
+		if b, _ := io.ReadAll(r.Body); string(b) != tt.want {
+			t.Errorf("%s: body after HasBody = %q, want %q", tt.name, b, tt.want)
+		}
+	}
+}
+
+func TestPreferredCharset(t *testing.T) {
+	tests := []struct{ header, want string }{
+		{"", "utf-8"},
+		{"iso-8859-1;q=0.5, UTF-8;q=0.9", "utf-8"},
+		{"iso-8859-1, utf-8;q=0.7", "iso-8859-1"},
+		{"shift_jis", "utf-8"},
+		{"utf-8;q=0, *;q=0.3", "iso-8859-1"},
+		{"*", "utf-8"},
+		{"utf-8;q=0.2, iso-8859-1;q=0.2", "utf-8"},
+		{"iso-8859-1;q=0.2, utf-8;q=0.2", "utf-8"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Accept-Charset", tt.header)
+		}
+		if got := PreferredCharset(r, "utf-8", "iso-8859-1"); got != tt.want {
+			t.Errorf("Accept-Charset %q: PreferredCharset = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package yar
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ServiceUnavailable replies with a 503 and a Retry-After header giving
+// retryAfter in whole seconds, rounded up. The header is left out if
+// retryAfter is zero or less.
+func ServiceUnavailable(w http.ResponseWriter, retryAfter time.Duration) {
+	if retryAfter > 0 {
+		secs := (retryAfter + time.Second - 1) / time.Second
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(secs), 10))
+	}
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// Render executes the template name of t with data and replies with status
+// and the result. The template is executed into a buffer first, so if it
+// fails nothing of it is sent, the client gets a 500 and the error is
+// returned.
+func Render(w http.ResponseWriter, status int, t *template.Template, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// SafeRedirect redirects to next, e.g. the ?next= of a login form, only if
+// it is a relative URL or an absolute http(s) one for the host of r, and to
+// fallback otherwise, so it can't be used as an open redirect. URLs with a
+// backslash or control characters, which browsers may read as another
+// host, always get the fallback.
+func SafeRedirect(w http.ResponseWriter, r *http.Request, next, fallback string) {
+	if !safeRedirect(r, next) {
+		next = fallback
+	}
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+func safeRedirect(r *http.Request, next string) bool {
+	if next == "" || strings.Contains(next, `\`) || strings.IndexFunc(next, unicode.IsControl) >= 0 {
+		return false
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return u.User == nil
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.User == nil &&
+		strings.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,77 @@
+package yar
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceUnavailable(t *testing.T) {
+	tests := map[time.Duration]string{
+		90 * time.Second:        "90",
+		1500 * time.Millisecond: "2",
+		time.Millisecond:        "1",
+		0:                       "",
+		-time.Second:            "",
+	}
+	for retryAfter, want := range tests {
+		w := httptest.NewRecorder()
+		ServiceUnavailable(w, retryAfter)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%v: status %d, want 503", retryAfter, w.Code)
+		}
+		got, set := w.Header()["Retry-After"]
+		if want == "" && set || want != "" && (len(got) != 1 || got[0] != want) {
+			t.Errorf("%v: Retry-After %q, want %q", retryAfter, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl := template.Must(template.New("broken").Parse(`hello {{.Name}}{{template "missing"}}`))
+	template.Must(tmpl.New("ok").Parse(`hi {{.}}`))
+
+	w := httptest.NewRecorder()
+	if err := Render(w, http.StatusOK, tmpl, "broken", struct{ Name string }{"a"}); err == nil {
+		t.Error("Render of a failing template returned no error")
+	}
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "hello") {
+		t.Errorf("failing template = %d %q, want a 500 without the partial body", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	if err := Render(w, http.StatusCreated, tmpl, "ok", "<b>"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusCreated || w.Body.String() != "hi &lt;b&gt;" {
+		t.Errorf("Render = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/dash?x=1":              "/dash?x=1",
+		"dash":                   "/dash",
+		"http://example.com/ok":  "http://example.com/ok",
+		"https://EXAMPLE.com/ok": "https://EXAMPLE.com/ok",
+		"https://evil.com/":      "/home",
+		"//evil.com":             "/home",
+		"/\\evil.com":            "/home",
+		"\\\\evil.com":           "/home",
+		"/a\nb":                  "/home",
+		"javascript:alert(1)":    "/home",
+		"https://a@example.com/": "/home",
+		"":                       "/home",
+	}
+	for next, want := range tests {
+		r := httptest.NewRequest("GET", "http://example.com/login", nil)
+		w := httptest.NewRecorder()
+		SafeRedirect(w, r, next, "/home")
+		if w.Code != http.StatusFound || w.Header().Get("Location") != want {
+			t.Errorf("next %q: %d to %q, want %q", next, w.Code, w.Header().Get("Location"), want)
+		}
+	}
+}
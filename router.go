@@ -2,23 +2,90 @@ package yar // Yet Another Router
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"regexp/syntax"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
 )
 
 const (
-	ParamRegex = "<([A-z0-9_]*?)>"	// regexp to match variable declarations
-	ParamMatch = "([A-z0-9_].*?)"	// regexp to extract variables from the URI
+	ParamRegex = "<([A-z0-9_]*?\\??)(?::([^>]*))?>" // regexp to match variable declarations
+	ParamMatch = "([^/]+?)"                         // regexp to extract variables from the URI, within one segment
 )
 
+var (
+	paramRegexp   = regexp.MustCompile(ParamRegex)
+	varNameRegexp = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+)
+
+// paramMatchSegment is used instead of ParamMatch for a variable that ends its
+// segment, so it takes the rest of the segment rather than a single character
+const paramMatchSegment = "([^/]+)"
+
+// the captures for a variable declared as <name?>, which can be empty
+const (
+	paramMatchEmpty        = "([^/]*?)"
+	paramMatchEmptySegment = "([^/]*)"
+)
+
+// StatusClientClosedRequest is the non-standard status (used by nginx) set
+// for requests the client gave up on before they were handled
+const StatusClientClosedRequest = 499
+
+// SubtreeParam is the variable HandleSubtree stores the rest of the path in
+const SubtreeParam = "subpath"
+
 // Route is a route that contains a regexp and func to call
 type Route struct {
 	Pattern *regexp.Regexp
 	Func    http.HandlerFunc
+	subtree bool // registered with HandleSubtree, sorted after other routes
+	params  *ParameterRoute
+	// the pattern as it was registered, if it had variables
+	declared string
+	// if set the route only matches requests it returns true for
+	guard func(r *http.Request, path string) bool
+	// int(min..max) constraints the variables have to be in for it to match
+	ranges []paramRange
+	// literal text every match starts with, at the start of the path if
+	// anchored. Checked before the regexp so routes sharing a prefix that
+	// isn't in the path are skipped cheaply.
+	prefix   string
+	anchored bool
+}
+
+// setPrefix works out the literal prefix of the route's regexp
+func (route *Route) setPrefix() {
+	route.prefix, _ = route.Pattern.LiteralPrefix()
+	route.anchored = false
+	re, err := syntax.Parse(route.Pattern.String(), syntax.Perl)
+	if err != nil {
+		return
+	}
+	re = re.Simplify()
+	route.anchored = re.Op == syntax.OpBeginText ||
+		(re.Op == syntax.OpConcat && len(re.Sub) > 0 && re.Sub[0].Op == syntax.OpBeginText)
+}
+
+// mightMatch reports if path has the literal prefix of the route
+func (route *Route) mightMatch(path string) bool {
+	if route.prefix == "" {
+		return true
+	}
+	if route.anchored {
+		return strings.HasPrefix(path, route.prefix)
+	}
+	return strings.Contains(path, route.prefix)
 }
 
 // ParameterRoute is a route that has variables in the URI
@@ -29,23 +96,63 @@ type ParameterRoute struct {
 }
 
 // Extracts the "variable form" from the url and prepends them to the RawQuery
-// of the http.Request object
-// The user can then call the Parse function to get these form.
+// of the http.Request object, in the order they appear in the pattern. The
+// original query is kept as it was, including the order of keys and
+// repeated keys.
+// The user can then call the Parse function to get these form, or ParamValues
+// to get them in the order they appear in the pattern.
 // NOTE: The form will appear in the Form field of the http.Request, if its a
 // GET request the value will be the first in the slice but if its a PUT or POST
 // it will the last.
 func (pr *ParameterRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	vars := pr.Regexp.FindStringSubmatch(r.URL.Path)[1:]
-	form := url.Values{}
+	pr.serve(w, r, r.URL.Path)
+}
+
+// handlerFor returns a function that extracts the variables from path, which
+// is the path the Router matched and may differ from r.URL.Path (e.g. when
+// a trailing "/" was stripped)
+func (pr *ParameterRoute) handlerFor(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pr.serve(w, r, path)
+	}
+}
+
+func (pr *ParameterRoute) serve(w http.ResponseWriter, r *http.Request, path string) {
+	match := pr.Regexp.FindStringSubmatch(path)
+	if match == nil {
+		http.NotFound(w, r)
+		return
+	}
+	vars := match[1:]
+	// encode straight into one buffer rather than going through url.Values,
+	// which allocates a map, a slice per key and sorts the keys
+	size := len(r.URL.RawQuery) + 1
 	for i, vn := range pr.VarNames {
-		form.Add(vn, vars[i])
+		size += len(vn) + len(vars[i]) + 2
+	}
+	var b strings.Builder
+	b.Grow(size)
+	for i, vn := range pr.VarNames {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(vn))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(vars[i]))
 	}
 	// idea got from here - https://github.com/bmizerany/pat/blob/master/mux.go
-	r.URL.RawQuery = form.Encode() + "&" + r.URL.RawQuery
+	if r.URL.RawQuery != "" {
+		b.WriteByte('&')
+		b.WriteString(r.URL.RawQuery)
+	}
+	r.URL.RawQuery = b.String()
+	r = withParams(r, pr.VarNames, vars)
+	setRouted(w, r)
 	pr.Func(w, r)
 }
 
-// Routes is an array of routes that is sorted by regex length
+// Routes is an array of routes that is sorted by regex length, with subtree
+// routes last
 type Routes []*Route
 
 func (r Routes) Len() int {
@@ -57,6 +164,9 @@ func (r Routes) Swap(i, j int) {
 }
 
 func (r Routes) Less(i, j int) bool {
+	if r[i].subtree != r[j].subtree {
+		return !r[i].subtree
+	}
 	return len(r[i].Pattern.String()) > len(r[j].Pattern.String())
 }
 
@@ -69,106 +179,1065 @@ type Router struct {
 	Routes Routes
 	// should trailing / be stripped from path
 	Strip bool
-	// log requests?
-	Log         bool
+	// treat /a and /a/ as different paths so they can have their own
+	// handlers, Strip has no effect when it is set
+	ExactSlash bool
+	// log requests? and errors writing their responses
+	Log bool
+	// paths that are never logged, e.g. ones hit by scanners
+	IgnoreLogPaths []string
+	// format of the request log lines, defaults to LogText
+	LogFormat LogFormat
+	// where log lines are written, defaults to the standard logger
+	Logger      Logger
 	CheckRegexp bool
 	// 404 handler, defaults to http.NotFound
 	NotFound http.HandlerFunc
+	// 405 handler, defaults to MethodNotAllowed
+	MethodNotAllowed http.HandlerFunc
+	// Deprecated: it has no effect, Use middleware wraps NotFound (and
+	// NoMatch) whether it is set or not. UseMatched adds middleware that
+	// only wraps matched routes.
+	MiddlewareOnNotFound bool
+	// if set this is called instead of NotFound and MethodNotAllowed, so
+	// both cases can be handled by one function
+	NoMatch func(http.ResponseWriter, *http.Request, NoMatchReason)
+	// add a Server-Timing header with the time spent routing and handling
+	// the request. This is best-effort: the header is set when the handler
+	// writes its headers (or returns without writing), so the duration
+	// stops at that point and streaming responses only report the time to
+	// the first write.
+	ServerTiming bool
+	// Content-Type set for responses whose handler didn't set one before
+	// writing, so browsers don't sniff it
+	DefaultContentType string
+	// check the regexp Routes before FixedRoutes, so a catch-all regexp can
+	// take over paths that also have a fixed route. Every request then pays
+	// for a scan of the regexps, even ones a map lookup would have found.
+	RegexFirst bool
+	// return an error when a new route overlaps one in the other table, e.g.
+	// a fixed route that an existing regexp also matches. If not set the
+	// overlap is only logged when Log is on.
+	StrictRoutes bool
+	// called before anything else in ServeHTTP, it can change r.URL.Path to
+	// rewrite legacy URLs before matching. If it returns false it has
+	// written the response and routing is skipped.
+	Rewrite func(http.ResponseWriter, *http.Request) bool
+	// don't call the handler for requests whose context is already done
+	// (e.g. the client disconnected), they get StatusClientClosedRequest
+	SkipCancelled bool
+	// Retry-After sent with 503 responses, omitted if zero
+	RetryAfter time.Duration
+	// recover panics in handlers so they only fail their own request, the
+	// client gets a 500 unless PanicHandler is set
+	Recover bool
+	// called with the recovered value when Recover is set, if it doesn't
+	// write a response a 500 is sent
+	PanicHandler func(http.ResponseWriter, *http.Request, interface{})
+	// called after every request, e.g. to record metrics
+	OnRequest func(RequestInfo)
+	// called when a validator of HandleValidated rejects a value, if not
+	// set the request falls through to the other routes
+	ValidationFailed http.HandlerFunc
+	// when routes are registered with Host, requests for any other host get
+	// a 421 Misdirected Request instead of being matched against the routes
+	// of this router
+	StrictHost bool
+	// match routes against r.URL.EscapedPath() rather than r.URL.Path, so
+	// an encoded "/" (%2F) stays inside one segment, e.g. /files/a%2Fb
+	// matches /files/<name>. Use it when values can contain "/" or a proxy
+	// in front decodes paths. Patterns and fixed routes are then compared
+	// with the escaped path and variables get escaped values, which can be
+	// decoded with url.PathUnescape.
+	UseEscapedPath bool
+	// count the bytes of request bodies read and responses written, see
+	// Stats. Off by default as request bodies have to be wrapped.
+	CountBytes bool
+	// serve precompressed .br or .gz siblings of files served with Static
+	// to clients that accept them
+	ServePrecompressed bool
+	// match methods case-insensitively by upper casing them, so "get" finds
+	// a GET route. Handlers still see the method as it was sent. Methods
+	// are case-sensitive per the spec, but some clients send them in lower
+	// case. On by default.
+	NormalizeMethod bool
+	// requests with more header fields than this get a 431, 0 means no limit
+	MaxHeaders int
+	// allow a variable name more than once in a pattern, as in
+	// /<id>/to/<id>. Param returns the first value and ParamAll all of them.
+	// If not set such patterns are an error.
+	DuplicateParams bool
+	// register patterns like "/foo?x=1" as "/foo" instead of returning an
+	// error, routes only match the path so the query would never match
+	StripPatternQuery bool
+	// if set it replaces the matching of FixedRoutes and Routes, returning
+	// the function for a request and its variables (read with Param), or
+	// false if nothing matches. Everything else ServeHTTP does still applies.
+	Matcher func(method, path string) (http.HandlerFunc, map[string]string, bool)
+
+	// guards the route tables, so routes can be added or reset while serving
+	mu         sync.RWMutex
+	guarded    Routes // routes with a guard, checked before the others
+	hosts      map[string]*Router
+	parent     *Router // the router a Host router was created by
+	methods    map[string]*methodRoute
+	middleware []Middleware // added with Use
+	matched    []Middleware // added with UseMatched
+	spaIndex   string
+	disabled   map[string]bool // keys of routes turned off with SetRouteEnabled
+	errorPages map[int]http.HandlerFunc
+
+	// the response for HandleTimeout, set with TimeoutResponse
+	timeoutStatus int
+	timeoutBody   []byte
+
+	maintenance toggle
+	draining    toggle
+	sampler     atomic.Pointer[sampler]
+	formats     []string
+	stats       byteStats
+}
+
+// toggle is a switch that can be flipped while serving, when on it applies to
+// every path except the exempt ones
+type toggle struct {
+	on     atomic.Bool
+	exempt atomic.Value // map[string]bool
+}
+
+func (t *toggle) set(on bool, exempt []string) {
+	paths := map[string]bool{}
+	for _, p := range exempt {
+		paths[p] = true
+	}
+	t.exempt.Store(paths)
+	t.on.Store(on)
+}
+
+// applies reports if the toggle is on for path
+func (t *toggle) applies(path string) bool {
+	if !t.on.Load() {
+		return false
+	}
+	exempt, _ := t.exempt.Load().(map[string]bool)
+	return !exempt[path]
 }
 
 // NewRouter returns a Router
 func NewRouter() *Router {
 	return &Router{
-		FixedRoutes: map[string]http.HandlerFunc{},
-		Routes:      Routes{},
-		Strip:       false,
-		Log:         false,
-		CheckRegexp: true,
-		NotFound:    http.NotFound,
+		FixedRoutes:      map[string]http.HandlerFunc{},
+		Routes:           Routes{},
+		Strip:            false,
+		Log:              false,
+		CheckRegexp:      true,
+		NotFound:         http.NotFound,
+		MethodNotAllowed: MethodNotAllowed,
+		RetryAfter:       time.Minute,
+		NormalizeMethod:  true,
+	}
+}
+
+// Maintenance turns maintenance mode on or off. While on every request gets
+// a 503 except for the exempt paths (e.g. a health check). It is safe to
+// call while the router is serving.
+func (rtr *Router) Maintenance(on bool, exempt ...string) {
+	rtr.maintenance.set(on, exempt)
+}
+
+// Draining is for graceful shutdown, while on new requests get a 503 with
+// "Connection: close" except for the exempt paths. Requests already being
+// handled are not affected. It is safe to call from a signal handler while
+// the router is serving.
+func (rtr *Router) Draining(on bool, exempt ...string) {
+	rtr.draining.set(on, exempt)
+}
+
+// SPAFallback serves the file at indexPath for GET requests that accept HTML
+// and don't match any route, so client side routes of a single page app load
+// the app. Other requests that don't match still get NotFound.
+func (rtr *Router) SPAFallback(indexPath string) {
+	rtr.spaIndex = indexPath
+}
+
+func (rtr *Router) serveSPA(w http.ResponseWriter, r *http.Request) bool {
+	if rtr.spaIndex == "" || r.Method != http.MethodGet ||
+		!strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	f, err := os.Open(rtr.spaIndex)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		return false
 	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+	return true
 }
 
-func (rtr *Router) HandleFunc(pattern string, f http.HandlerFunc) {
-	re := regexp.MustCompile(ParamRegex)
-	vars := re.FindAllString(pattern, -1)
+func (rtr *Router) unavailable(w http.ResponseWriter, r *http.Request) {
+	ServiceUnavailable(w, rtr.RetryAfter)
+}
+
+// HandleFunc registers f for pattern, it returns an error if the pattern is
+// already registered.
+// A regexp pattern can start with a flag group, e.g. "(?i)^/users$" matches
+// "/Users". All of Go's flags (i, m, s and U) are honored as the pattern is
+// compiled as given, including in patterns with variables. Named groups like
+// (?P<name>...) can't be used since <name> declares a variable.
+func (rtr *Router) HandleFunc(pattern string, f http.HandlerFunc) error {
+	if path, ok := rtr.patternQuery(pattern); ok {
+		if !rtr.StripPatternQuery {
+			return errors.New("Pattern has a query: " + pattern)
+		}
+		pattern = path
+	}
+	vars := paramRegexp.FindAllString(pattern, -1)
 	if len(vars) > 0 {
-		rtr.addProcessedParameterRoute(pattern, re, f)
+		return rtr.addProcessedParameterRoute(pattern, paramRegexp, f)
 	} else if rtr.CheckRegexp {
 		quoted := regexp.QuoteMeta(pattern)
 		if quoted == pattern {
-			rtr.addFixedRoute(pattern, f)
+			return rtr.addFixedRoute(pattern, f)
+		}
+		return rtr.addRoute(pattern, f)
+	}
+	return rtr.addFixedRoute(pattern, f)
+}
+
+// queryPatternRegexp finds what looks like the start of a query, "?key=",
+// in a pattern that may be a regexp
+var queryPatternRegexp = regexp.MustCompile(`\?[A-Za-z0-9_.~%-]+=`)
+
+// patternQuery returns pattern without a query string and true if it has
+// one. Routes only match the path, so a pattern like "/foo?x=1" could never
+// match. If CheckRegexp is set "?" is a regexp operator, so only "?key="
+// counts as a query.
+func (rtr *Router) patternQuery(pattern string) (string, bool) {
+	i := strings.IndexByte(pattern, '?')
+	if rtr.CheckRegexp {
+		loc := queryPatternRegexp.FindStringIndex(pattern)
+		if loc == nil {
+			return pattern, false
+		}
+		i = loc[0]
+	}
+	if i < 0 {
+		return pattern, false
+	}
+	return pattern[:i], true
+}
+
+// Handle registers the handler h for pattern, like HandleFunc
+func (rtr *Router) Handle(pattern string, h http.Handler) error {
+	return rtr.HandleFunc(pattern, h.ServeHTTP)
+}
+
+// HandleFuncf registers f for the pattern fmt.Sprintf(format, args...), e.g.
+// for routes generated in a loop. It returns an error if the arguments don't
+// match the format or the pattern doesn't start with "/".
+func (rtr *Router) HandleFuncf(format string, args []interface{}, f http.HandlerFunc) error {
+	pattern := fmt.Sprintf(format, args...)
+	if strings.Contains(pattern, "%!") {
+		return errors.New("Bad format: " + pattern)
+	}
+	if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "^/") {
+		return errors.New("Pattern must start with /: " + pattern)
+	}
+	return rtr.HandleFunc(pattern, f)
+}
+
+// HandleAliases registers f under each of patterns, e.g. /color and
+// /colour. Every pattern is tried even if one fails, and the errors of the
+// ones that did are returned joined together.
+func (rtr *Router) HandleAliases(patterns []string, f http.HandlerFunc) error {
+	var errs []error
+	for _, pattern := range patterns {
+		if err := rtr.HandleFunc(pattern, f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HandleWithClass registers f for pattern like HandleFunc, but variables
+// without a constraint match the regexp class instead of any characters up
+// to the next "/", e.g. "[a-z]+". It is the same as writing <name:class>
+// for each of them, variables declared as <name?> are left as they are.
+func (rtr *Router) HandleWithClass(pattern, class string, f http.HandlerFunc) error {
+	if _, err := syntax.Parse(class, syntax.Perl); err != nil {
+		return errors.New("Bad class " + class + ": " + err.Error())
+	}
+	pattern = paramRegexp.ReplaceAllStringFunc(pattern, func(v string) string {
+		m := paramRegexp.FindStringSubmatch(v)
+		if m[2] != "" || strings.HasSuffix(m[1], "?") {
+			return v
+		}
+		return "<" + m[1] + ":" + class + ">"
+	})
+	return rtr.HandleFunc(pattern, f)
+}
+
+// HandleSubtree registers f for prefix and every path under it, so "/docs"
+// matches "/docs" and "/docs/intro". The rest of the path (e.g. "intro") is
+// stored in the SubtreeParam variable, and with the leading "/" by
+// PathRemainder. Other routes under the prefix are checked first.
+func (rtr *Router) HandleSubtree(prefix string, f http.HandlerFunc) error {
+	prefix = strings.TrimSuffix(prefix, "/")
+	re := regexp.MustCompile(subtreeKey(prefix))
+	pr := &ParameterRoute{func(w http.ResponseWriter, r *http.Request) {
+		r = withRemainder(r, prefix)
+		setRouted(w, r)
+		f(w, r)
+	}, []string{SubtreeParam}, re}
+	return rtr.insertRoute(&Route{Pattern: re, Func: pr.ServeHTTP, subtree: true, params: pr})
+}
+
+// subtreeKey returns the key a HandleSubtree of prefix, without a trailing
+// "/", is stored under
+func subtreeKey(prefix string) string {
+	return "^" + regexp.QuoteMeta(prefix) + "(?:/(.*))?$"
+}
+
+// Use adds middleware that wraps every request that is routed, in the order
+// given, including those no route matches (NotFound, NoMatch and the
+// SPAFallback). Responses sent while Draining or in Maintenance, and those
+// for StrictHost, don't go through it. The middleware of a Host only wraps
+// matches of its own routes.
+//
+// The first middleware is the outermost, Use middleware wraps UseMatched
+// middleware and the router's middleware wraps that of a Host.
+// Middleware can pass its own ResponseWriter on (e.g. to compress or buffer
+// the body), handlers further in then write to it. The writer the router
+// uses to record the status for logging, Server-Timing and OnRequest is
+// outside all middleware, so it sees what is finally sent.
+func (rtr *Router) Use(mw ...Middleware) {
+	rtr.middleware = append(rtr.middleware, mw...)
+}
+
+// UseMatched adds middleware that only wraps requests a route matches, in
+// the order given. It runs inside the Use middleware.
+func (rtr *Router) UseMatched(mw ...Middleware) {
+	rtr.matched = append(rtr.matched, mw...)
+}
+
+// HandleNotFound sets NotFound to f, like the Router's other 404s it goes
+// through the Use middleware
+func (rtr *Router) HandleNotFound(f http.HandlerFunc) {
+	rtr.NotFound = f
+}
+
+// HandleGone registers pattern to reply with 410 Gone, so clients and
+// crawlers learn a removed resource won't come back
+func (rtr *Router) HandleGone(pattern string) error {
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+	})
+}
+
+// HandleNoContent registers pattern to reply with an uncacheable 204 No
+// Content, e.g. for beacons and pings
+func (rtr *Router) HandleNoContent(pattern string) error {
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// HandleRedirect registers pattern to redirect to target with code, which
+// must be a 3xx status. Variables of pattern can be used in target, so
+// "/u/<id>" to "/users/<id>" redirects /u/5 to /users/5. Values are path
+// escaped. It returns an error if target uses a variable pattern doesn't
+// declare.
+func (rtr *Router) HandleRedirect(pattern, target string, code int) error {
+	if code < 300 || code > 399 {
+		return errors.New("Not a redirect status: " + strconv.Itoa(code))
+	}
+	declared := []string{}
+	for _, m := range paramRegexp.FindAllStringSubmatch(pattern, -1) {
+		declared = append(declared, strings.TrimSuffix(m[1], "?"))
+	}
+	for _, m := range paramRegexp.FindAllStringSubmatch(target, -1) {
+		if name := strings.TrimSuffix(m[1], "?"); !containsString(declared, name) {
+			return errors.New("No variable <" + name + "> in " + pattern)
+		}
+	}
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		to := paramRegexp.ReplaceAllStringFunc(target, func(v string) string {
+			name := strings.TrimSuffix(paramRegexp.FindStringSubmatch(v)[1], "?")
+			return url.PathEscape(Param(r, name))
+		})
+		http.Redirect(w, r, to, code)
+	})
+}
+
+// HandleSelect registers several functions for the same pattern, selector is
+// called on each request and returns the index of the function to call.
+// An index that is out of range is treated as not found.
+func (rtr *Router) HandleSelect(pattern string, selector func(*http.Request) int, handlers ...http.HandlerFunc) error {
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		i := selector(r)
+		if i < 0 || i >= len(handlers) {
+			rtr.notFound(w, r)
+			return
+		}
+		handlers[i](w, r)
+	})
+}
+
+// Reset removes all routes, including those of hosts and the functions
+// registered per method or content type. Configuration and handlers such as
+// NotFound are kept.
+func (rtr *Router) Reset() {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	rtr.FixedRoutes = map[string]http.HandlerFunc{}
+	rtr.Routes = Routes{}
+	rtr.guarded = nil
+	rtr.disabled = nil
+	rtr.hosts = nil
+	rtr.methods = nil
+}
+
+// SetRouteEnabled turns the routes registered for pattern off or back on,
+// pattern is as it was registered, the prefix for HandleSubtree, Mount and
+// StaticFS. While off the router behaves as if they weren't registered, so
+// requests fall through to other routes or NotFound. It returns an error if
+// there is no such route.
+func (rtr *Router) SetRouteEnabled(pattern string, enabled bool) error {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	found := false
+	for _, key := range patternKeys(pattern) {
+		if !rtr.hasRoute(key) {
+			continue
+		}
+		found = true
+		if enabled {
+			delete(rtr.disabled, key)
+			continue
+		}
+		if rtr.disabled == nil {
+			rtr.disabled = map[string]bool{}
+		}
+		rtr.disabled[key] = true
+	}
+	if !found {
+		return errors.New("No route: " + pattern)
+	}
+	return nil
+}
+
+// patternKeys returns the keys the routes registered for pattern can be
+// stored under, depending on how they were registered
+func patternKeys(pattern string) []string {
+	if paramRegexp.MatchString(pattern) {
+		return []string{routeKey(pattern)}
+	}
+	prefix := strings.TrimSuffix(pattern, "/")
+	return []string{pattern, guardedKey(pattern), subtreeKey(prefix), mountRouteKey(prefix)}
+}
+
+// hasRoute reports if there is a route stored under key, rtr.mu must be held
+func (rtr *Router) hasRoute(key string) bool {
+	if _, ok := rtr.FixedRoutes[key]; ok {
+		return true
+	}
+	for _, routes := range []Routes{rtr.Routes, rtr.guarded} {
+		for _, rr := range routes {
+			if rr.Pattern.String() == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Merge adds the routes of other to rtr, e.g. routes a library registered
+// on its own Router. It returns an error and adds nothing if one of them is
+// already registered on rtr. Only routes are merged, the configuration,
+// middleware and hosts of other are not: routes registered with
+// HandleMethod or HandleContentType are copied to rtr, so its NoMatch,
+// MethodNotAllowed and NormalizeMethod apply to them.
+func (rtr *Router) Merge(other *Router) error {
+	other.mu.RLock()
+	methods := make(map[string]*methodRoute, len(other.methods))
+	for k, mr := range other.methods {
+		methods[k] = &methodRoute{rtr, copyHandlers(mr.handlers), copyHandlers(mr.contentTypes)}
+	}
+	// the function for key, bound to rtr's copy if it dispatches itself
+	rebind := func(key string, f http.HandlerFunc) http.HandlerFunc {
+		if mr, ok := methods[key]; ok {
+			return mr.ServeHTTP
+		}
+		return f
+	}
+	fixed := make(map[string]http.HandlerFunc, len(other.FixedRoutes))
+	for p, f := range other.FixedRoutes {
+		fixed[p] = rebind(p, f)
+	}
+	routes := make(Routes, 0, len(other.Routes))
+	for _, route := range other.Routes {
+		key := route.Pattern.String()
+		if _, ok := methods[key]; !ok {
+			routes = append(routes, route)
+			continue
+		}
+		copied := *route
+		if route.params != nil {
+			pr := *route.params
+			pr.Func = rebind(key, pr.Func)
+			copied.params, copied.Func = &pr, pr.ServeHTTP
 		} else {
-			rtr.addRoute(pattern, f)
+			copied.Func = rebind(key, route.Func)
 		}
-	} else {
-		rtr.addFixedRoute(pattern, f)
+		routes = append(routes, &copied)
 	}
+	guarded := append(Routes{}, other.guarded...)
+	other.mu.RUnlock()
+
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	for p := range fixed {
+		if _, exists := rtr.FixedRoutes[p]; exists {
+			return errors.New("Key exists: " + p)
+		}
+	}
+	for _, list := range [][2]Routes{{routes, rtr.Routes}, {guarded, rtr.guarded}} {
+		for _, route := range list[0] {
+			for _, r := range list[1] {
+				if r.Pattern.String() == route.Pattern.String() {
+					return errors.New("Key exists: " + r.Pattern.String())
+				}
+			}
+		}
+	}
+	for k := range methods {
+		if _, exists := rtr.methods[k]; exists {
+			return errors.New("Key exists: " + k)
+		}
+	}
+	for p, f := range fixed {
+		rtr.FixedRoutes[p] = f
+	}
+	rtr.Routes = append(rtr.Routes, routes...)
+	sort.Sort(rtr.Routes)
+	rtr.guarded = append(rtr.guarded, guarded...)
+	sort.Stable(rtr.guarded)
+	if len(methods) > 0 && rtr.methods == nil {
+		rtr.methods = map[string]*methodRoute{}
+	}
+	for k, mr := range methods {
+		rtr.methods[k] = mr
+	}
+	return nil
+}
+
+func copyHandlers(handlers map[string]http.HandlerFunc) map[string]http.HandlerFunc {
+	copied := make(map[string]http.HandlerFunc, len(handlers))
+	for k, f := range handlers {
+		copied[k] = f
+	}
+	return copied
+}
+
+// canaryIntn returns a number in [0,n), it is a variable so tests can use a
+// seeded source
+var canaryIntn = rand.Intn
+
+// HandleCanary registers pattern so that canaryPercent of requests, clamped
+// to 0..100, go to canary and the rest to stable. Each request is drawn at
+// random.
+func (rtr *Router) HandleCanary(pattern string, stable, canary http.HandlerFunc, canaryPercent int) error {
+	if canaryPercent < 0 {
+		canaryPercent = 0
+	} else if canaryPercent > 100 {
+		canaryPercent = 100
+	}
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if canaryIntn(100) < canaryPercent {
+			canary(w, r)
+			return
+		}
+		stable(w, r)
+	})
 }
 
 func (rtr *Router) addFixedRoute(pattern string, f http.HandlerFunc) error {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
 	if _, exists := rtr.FixedRoutes[pattern]; exists {
 		return errors.New("Key exists: " + pattern)
 	}
+	for _, r := range rtr.Routes {
+		if rtr.shadows(r) && r.Pattern.MatchString(pattern) {
+			if err := rtr.overlap(pattern, r.Pattern.String()); err != nil {
+				return err
+			}
+		}
+	}
 	rtr.FixedRoutes[pattern] = f
 	return nil
 }
 
 func (rtr *Router) addRoute(pattern string, f http.HandlerFunc) error {
-	re := regexp.MustCompile(pattern)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	return rtr.insertRoute(&Route{Pattern: re, Func: f})
+}
+
+func (rtr *Router) insertRoute(route *Route) error {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	re := route.Pattern
 	for _, r := range rtr.Routes {
 		if r.Pattern.String() == re.String() {
-			return errors.New("Key exists: " + pattern)
+			return errors.New("Key exists: " + re.String())
+		}
+	}
+	for fixed := range rtr.FixedRoutes {
+		if rtr.shadows(route) && re.MatchString(fixed) {
+			if err := rtr.overlap(re.String(), fixed); err != nil {
+				return err
+			}
 		}
 	}
-	rtr.Routes = append(rtr.Routes, &Route{re, f})
+	route.setPrefix()
+	rtr.Routes = append(rtr.Routes, route)
 	sort.Sort(rtr.Routes)
 	return nil
 }
 
-func (rtr *Router) addParameterRoute(pattern string, f http.HandlerFunc) {
-	re := regexp.MustCompile(ParamRegex)
-	rtr.addProcessedParameterRoute(pattern, re, f)
+// shadows reports if a fixed route that route also matches is a conflict.
+// HandleSubtree and Mount routes are meant to have other routes under their
+// prefix, which are checked first unless RegexFirst is set.
+func (rtr *Router) shadows(route *Route) bool {
+	return !route.subtree || rtr.RegexFirst
+}
+
+// overlap reports that pattern overlaps an existing route, it returns an
+// error if StrictRoutes is set
+func (rtr *Router) overlap(pattern, existing string) error {
+	msg := "Route overlaps: " + pattern + " and " + existing
+	if rtr.StrictRoutes {
+		return errors.New(msg)
+	}
+	if rtr.Log {
+		rtr.logln(msg)
+	}
+	return nil
+}
+
+func (rtr *Router) addParameterRoute(pattern string, f http.HandlerFunc) error {
+	return rtr.addProcessedParameterRoute(pattern, paramRegexp, f)
+}
+
+func (rtr *Router) addProcessedParameterRoute(pattern string, re *regexp.Regexp, f http.HandlerFunc) error {
+	route, err := rtr.newParameterRoute(pattern, re, f)
+	if err != nil {
+		return err
+	}
+	return rtr.insertRoute(route)
+}
+
+func (rtr *Router) newParameterRoute(pattern string, re *regexp.Regexp, f http.HandlerFunc) (*Route, error) {
+	newPattern, varNames, ranges, err := expandParams(pattern, re)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, vn := range varNames {
+		if !varNameRegexp.MatchString(vn) {
+			return nil, errors.New("Invalid variable name: <" + vn + "> in " + pattern)
+		}
+		if seen[vn] && !rtr.DuplicateParams {
+			return nil, errors.New("Duplicate variable: <" + vn + "> in " + pattern)
+		}
+		seen[vn] = true
+	}
+	compiled, err := regexp.Compile(newPattern)
+	if err != nil {
+		return nil, err
+	}
+	pr := &ParameterRoute{f, varNames, compiled}
+	return &Route{Pattern: pr.Regexp, Func: pr.ServeHTTP, params: pr, declared: pattern, ranges: ranges}, nil
 }
 
-func (rtr *Router) addProcessedParameterRoute(pattern string, re *regexp.Regexp, f http.HandlerFunc) {
+// expandParams replaces the variable declarations in pattern with ParamMatch,
+// or the capture for their constraint, and returns the new pattern, the
+// variable names and the ranges to check once it matches. Variables only
+// match within a segment, one followed by a literal in the same segment (as
+// in <w>x<h>) stops at the first occurrence of it.
+// A variable declared as <name?> can also be empty, so /a/<b?>/c matches
+// /a//c. Only the variable is optional, /a/<b?> matches /a/ but not /a (and
+// with Strip set /a/ is matched as /a). It can't have a constraint, the
+// constraint decides what it matches.
+// A variable declared as <name:int(min..max)> only matches integers in the
+// range, other values fall through to the other routes. As a regexp can't
+// express the range the pattern only has the shape of an integer and the
+// range is checked separately. The range is part of the route's key, so
+// /p/<n:int(1..10)> and /p/<n:int(11..20)> can both be registered, as can
+// /p/<n:int> for the values outside them. Ranges on the same path shouldn't
+// overlap, which route a value in both of them gets isn't defined.
+func expandParams(pattern string, re *regexp.Regexp) (string, []string, []paramRange, error) {
 	varNames := []string{}
-	vars := re.FindAllString(pattern, -1)
-	newPattern := pattern
-	for _, vn := range vars {
-		newPattern = strings.Replace(newPattern, vn, ParamMatch, 1)
-		varNames = append(varNames, vn[1:len(vn)-1]) // strip the < and > characters
+	var ranges []paramRange
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(pattern, -1) {
+		literal := pattern[last:m[0]]
+		if strings.HasSuffix(literal, ".") && !strings.HasSuffix(literal, `\.`) {
+			// a dot before a variable is an extension separator as in
+			// /data.<ext>, not any character
+			literal = literal[:len(literal)-1] + `\.`
+		}
+		b.WriteString(literal)
+		last = m[1]
+		name := pattern[m[2]:m[3]]
+		empty := strings.HasSuffix(name, "?")
+		name = strings.TrimSuffix(name, "?")
+		varNames = append(varNames, name)
+		spec := ""
+		if len(m) >= 6 && m[4] >= 0 {
+			spec = pattern[m[4]:m[5]]
+		}
+		if empty && spec != "" {
+			return "", nil, nil, errors.New("Variable <" + name + "?> can't have a constraint in " + pattern)
+		}
+		if spec == "" {
+			endsSegment := last == len(pattern) || pattern[last] == '/' || pattern[last] == '$'
+			switch {
+			case empty && endsSegment:
+				b.WriteString(paramMatchEmptySegment)
+			case empty:
+				b.WriteString(paramMatchEmpty)
+			case endsSegment:
+				b.WriteString(paramMatchSegment)
+			default:
+				b.WriteString(ParamMatch)
+			}
+			continue
+		}
+		var capture string
+		var err error
+		if strings.HasPrefix(spec, "int(") {
+			var r paramRange
+			if r, err = parseIntRange(spec); err == nil {
+				r.index = len(varNames) - 1
+				ranges = append(ranges, r)
+				capture = rangeCapture(r)
+			}
+		} else {
+			capture, err = constraintCapture(spec)
+		}
+		if err != nil {
+			return "", nil, nil, errors.New(err.Error() + " in " + pattern)
+		}
+		b.WriteString(capture)
+		if last == len(pattern) {
+			// nothing follows to end the value so end it at the segment
+			b.WriteString("(?:/|$)")
+		}
+	}
+	b.WriteString(pattern[last:])
+	return b.String(), varNames, ranges, nil
+}
+
+// routeKey returns the key pattern is stored under in FixedRoutes or Routes
+func routeKey(pattern string) string {
+	if paramRegexp.MatchString(pattern) {
+		key, _, _, _ := expandParams(pattern, paramRegexp)
+		return key
 	}
-	pr := ParameterRoute{f, varNames, regexp.MustCompile(newPattern)}
-	rtr.addRoute(newPattern, pr.ServeHTTP)
+	return pattern
 }
 
+// ServeHTTP routes the request. Routes are matched against r.URL.Path (or
+// its escaped form with UseEscapedPath) only, never r.RequestURI, so the
+// query and fragment can't affect which route matches or what variables
+// capture.
 func (rtr *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-	logMsg := "requested: " + path
-	// only strip "/" if its not the entire path
-	if rtr.Strip && len(path) > 1 && strings.HasSuffix(path, "/") {
-		// is this just overhead?
-		path = strings.TrimSuffix(path, "/")
-		logMsg += " (stripped to: " + path + ")"
+	if rtr.MaxHeaders > 0 && headerCount(r.Header) > rtr.MaxHeaders {
+		http.Error(w, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+		return
 	}
-	if rtr.Log {
-		log.Println(logMsg)
+	if rtr.Rewrite != nil && !rtr.Rewrite(w, r) {
+		return
+	}
+	requested := r.URL.Path
+	if rtr.UseEscapedPath {
+		requested = r.URL.EscapedPath()
 	}
-	if f, ok := rtr.FixedRoutes[path]; ok == true {
+	path := rtr.stripPath(requested)
+	logging := rtr.Log && !rtr.ignoreLog(path)
+	jsonLog := logging && rtr.LogFormat == LogJSON
+	if logging && !jsonLog {
+		logMsg := "requested: " + sanitize(requested)
+		if path != requested {
+			logMsg += " (stripped to: " + sanitize(path) + ")"
+		}
+		rtr.logln(logMsg)
+	}
+	if rtr.SkipCancelled && r.Context().Err() != nil {
+		if rtr.Log {
+			rtr.logln("cancelled: " + sanitize(requested) + " (" + r.Context().Err().Error() + ")")
+		}
+		w.WriteHeader(StatusClientClosedRequest)
+		return
+	}
+	f, pattern := rtr.handler(r, path)
+	rtr.sample(r, pattern)
+	if rtr.CountBytes && r.Body != nil && r.Body != http.NoBody {
+		r.Body = &countingBody{r.Body, &rtr.stats}
+	}
+	if !rtr.ServerTiming && !logging && rtr.DefaultContentType == "" &&
+		!rtr.Recover && rtr.OnRequest == nil && !rtr.CountBytes && !rtr.hasErrorPages() {
 		f(w, r)
 		return
+	}
+	rw := newResponseWriter(rtr, w)
+	rw.req = r
+	var p *recovered
+	if rtr.Recover {
+		p = rtr.callRecover(f, rw, r)
 	} else {
-		for _, rr := range rtr.Routes {
-			if rr.Pattern.MatchString(path) {
-				rr.Func(w, r)
-				return
-			}
+		f(rw, r)
+	}
+	rw.finish()
+	if rtr.CountBytes {
+		rtr.stats.responseBytes.Add(uint64(rw.written))
+	}
+	if jsonLog {
+		rtr.logJSON(r, requested, pattern, rw)
+	} else if logging && rw.writeErr != nil {
+		rtr.logln("write error: " + sanitize(requested) + ": " + sanitize(rw.writeErr.Error()))
+	}
+	if rtr.OnRequest != nil {
+		rtr.OnRequest(newRequestInfo(r, requested, pattern, rw, p))
+	}
+}
+
+// handler returns the function to call for path, and the key of the route
+// or "" if nothing matched
+func (rtr *Router) handler(r *http.Request, path string) (http.HandlerFunc, string) {
+	if rtr.draining.applies(path) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Connection", "close")
+			rtr.unavailable(w, r)
+		}, ""
+	}
+	if rtr.maintenance.applies(path) {
+		return rtr.unavailable, ""
+	}
+	sub, hosts := rtr.hostRouter(r)
+	if sub == nil && hosts && rtr.StrictHost {
+		return misdirected, ""
+	}
+	f, key := rtr.route(sub, r, path)
+	return chain(f, rtr.middleware), key
+}
+
+// route returns the function of the route that matches path, or the one
+// for no match, wrapped in the UseMatched middleware. A route registered for
+// the whole path, as in /data.json, wins over the routes for the path
+// without its format suffix.
+func (rtr *Router) route(sub *Router, r *http.Request, path string) (http.HandlerFunc, string) {
+	if base, format := rtr.splitFormat(path); format != "" && !sub.hasExact(path) && !rtr.hasExact(path) {
+		if f, key := rtr.find(sub, r, base); f != nil {
+			return withFormat(f, format), key
+		}
+	}
+	if f, key := rtr.find(sub, r, path); f != nil {
+		return f, key
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !rtr.serveSPA(w, r) {
+			rtr.notFound(w, r)
+		}
+	}, ""
+}
+
+// hasExact reports if rtr, which can be nil, has an enabled route whose
+// pattern is path itself. That is a fixed route or, as the "." in /data.json
+// makes it a regexp when CheckRegexp is set, a regexp route.
+func (rtr *Router) hasExact(path string) bool {
+	if rtr == nil || rtr.Matcher != nil {
+		return false
+	}
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	if rtr.disabled[path] {
+		return false
+	}
+	if _, ok := rtr.FixedRoutes[path]; ok {
+		return true
+	}
+	for _, rr := range rtr.Routes {
+		if rr.Pattern.String() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the function of the route that matches path, checking the
+// routes of the host router sub first, wrapped in the UseMatched middleware
+func (rtr *Router) find(sub *Router, r *http.Request, path string) (http.HandlerFunc, string) {
+	if sub != nil {
+		if f, key := sub.match(r, path); f != nil {
+			f = chain(chain(f, sub.matched), sub.middleware)
+			return chain(f, rtr.matched), key
+		}
+	}
+	if f, key := rtr.match(r, path); f != nil {
+		return chain(f, rtr.matched), key
+	}
+	return nil, ""
+}
+
+// headerCount returns the number of header fields, a repeated header counts
+// once for each value
+func headerCount(h http.Header) int {
+	n := 0
+	for _, v := range h {
+		n += len(v)
+	}
+	return n
+}
+
+// sanitize escapes control characters (e.g. newlines decoded from the URI) so
+// a logged value can't break or fake log lines
+func sanitize(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, c := range s {
+		if unicode.IsControl(c) {
+			q := strconv.QuoteRune(c)
+			b.WriteString(q[1 : len(q)-1])
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// stripPath removes a trailing "/" if Strip is set, ExactSlash isn't and its
+// not the entire path
+func (rtr *Router) stripPath(path string) string {
+	if rtr.Strip && !rtr.ExactSlash && len(path) > 1 && strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// match returns the function registered for path and the key it was stored
+// under, or nil if there is none. Guarded routes are skipped if r is nil.
+func (rtr *Router) match(r *http.Request, path string) (http.HandlerFunc, string) {
+	if rtr.Matcher != nil {
+		return rtr.customMatch(r, path)
+	}
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	if f, key := rtr.guarded.match(r, path, rtr.disabled); f != nil {
+		return f, key
+	}
+	if rtr.RegexFirst {
+		if f, key := rtr.Routes.match(r, path, rtr.disabled); f != nil {
+			return f, key
+		}
+		if rtr.disabled[path] {
+			return nil, ""
 		}
+		return rtr.FixedRoutes[path], path
 	}
-	rtr.NotFound(w, r)
+	if f, ok := rtr.FixedRoutes[path]; ok && !rtr.disabled[path] {
+		return f, path
+	}
+	return rtr.Routes.match(r, path, rtr.disabled)
+}
+
+// customMatch matches path with Matcher, the key is the path
+func (rtr *Router) customMatch(r *http.Request, path string) (http.HandlerFunc, string) {
+	method := ""
+	if r != nil {
+		method = rtr.method(r)
+	}
+	f, vars, ok := rtr.Matcher(method, path)
+	if !ok || f == nil {
+		return nil, ""
+	}
+	if len(vars) == 0 {
+		return f, path
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = vars[name]
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = withParams(r, names, values)
+		setRouted(w, r)
+		f(w, r)
+	}, path
 }
 
-// Parse parses r.URL.Query to extract the stored variables
+// match returns the function of the first route that matches path, skipping
+// those whose key is in disabled and guarded ones if r is nil
+func (routes Routes) match(r *http.Request, path string, disabled map[string]bool) (http.HandlerFunc, string) {
+	for _, rr := range routes {
+		if !rr.mightMatch(path) || !rr.Pattern.MatchString(path) {
+			continue
+		}
+		if len(disabled) > 0 && disabled[rr.Pattern.String()] {
+			continue
+		}
+		if rr.guard != nil && (r == nil || !rr.guard(r, path)) {
+			continue
+		}
+		if len(rr.ranges) > 0 && !rr.inRanges(path) {
+			continue
+		}
+		if rr.params != nil {
+			return rr.params.handlerFor(path), rr.Pattern.String()
+		}
+		return rr.Func, rr.Pattern.String()
+	}
+	return nil, ""
+}
+
+// inRanges reports if the variables in path with a range constraint are in
+// their range
+func (rr *Route) inRanges(path string) bool {
+	match := rr.Pattern.FindStringSubmatch(path)
+	if match == nil {
+		return false
+	}
+	for _, pr := range rr.ranges {
+		if !pr.in(match[pr.index+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse parses r.URL.Query to extract the stored variables. The first map
+// has the first value of each query key, which for the variables from the
+// URI is the captured value. The second has the remaining form values, that
+// is r.Form with one occurrence of each of those values removed. r.Form is
+// not modified.
+// Like r.ParseForm it only reads the body of url-encoded form posts, other
+// bodies (e.g. a chunked upload) are left for the handler to stream.
 func Parse(r *http.Request) (map[string]string, map[string][]string) {
 	m := map[string]string{}
 	for k, v := range r.URL.Query() {
@@ -176,21 +1245,43 @@ func Parse(r *http.Request) (map[string]string, map[string][]string) {
 	}
 
 	r.ParseForm()
-	form := map[string][]string{}
-
-	for k, values := range r.Form {
-		mValues, exists := m[k]
-		if !exists {
-			form[k] = values
-		} else if len(mValues) > 1 {
-			for i, v := range values {
-				if m[k] == v {
-					form[k] = append(values[:i], values[i+1:]...)
-					break
-				}
+	return m, remainingForm(r.Form, m)
+}
+
+// ParseMultipart is Parse for multipart/form-data requests. Up to maxMemory
+// bytes of the body are held in memory, the rest of any files go to
+// temporary files on disk, so keep it small for endpoints taking large
+// uploads. The field values are merged into the returned form the same way
+// as Parse, the files are in r.MultipartForm.File.
+func ParseMultipart(r *http.Request, maxMemory int64) (map[string]string, map[string][]string, error) {
+	m := map[string]string{}
+	for k, v := range r.URL.Query() {
+		m[k] = v[0]
+	}
+
+	if err := r.ParseMultipartForm(maxMemory); err != nil {
+		return m, nil, err
+	}
+	return m, remainingForm(r.Form, m), nil
+}
+
+// remainingForm returns a copy of form without the first occurrence of the
+// value in m for each key
+func remainingForm(form url.Values, m map[string]string) map[string][]string {
+	rest := map[string][]string{}
+	for k, values := range form {
+		mValue, exists := m[k]
+		remaining := make([]string, 0, len(values))
+		for _, v := range values {
+			if exists && v == mValue {
+				exists = false // only remove it once
+				continue
 			}
+			remaining = append(remaining, v)
+		}
+		if len(remaining) > 0 {
+			rest[k] = remaining
 		}
 	}
-
-	return m, form
+	return rest
 }
@@ -0,0 +1,894 @@
+package yar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func write(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	lib := NewRouter()
+	lib.HandleFunc("/lib", write("lib"))
+	lib.HandleMethod("GET", "/lib/<id>", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("id " + Param(r, "id")))
+	})
+	lib.HandleMethod("GET", "/lib/fixed", write("fixed"))
+
+	rtr := NewRouter()
+	rtr.MethodNotAllowed = func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+	if err := rtr.Merge(lib); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "GET", "/lib").Body.String(); got != "lib" {
+		t.Errorf("GET /lib = %q", got)
+	}
+	if got := serve(rtr, "GET", "/lib/2").Body.String(); got != "id 2" {
+		t.Errorf("GET /lib/2 = %q", got)
+	}
+	for _, path := range []string{"/lib/2", "/lib/fixed"} {
+		if w := serve(rtr, "POST", path); w.Code != http.StatusTeapot {
+			t.Errorf("POST %s = %d, want the receiver's MethodNotAllowed", path, w.Code)
+		}
+	}
+
+	// adding a method on rtr doesn't change lib, and lib's own routes are untouched
+	if err := rtr.HandleMethod("POST", "/lib/<id>", write("posted")); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "POST", "/lib/2").Body.String(); got != "posted" {
+		t.Errorf("POST /lib/2 after HandleMethod = %q", got)
+	}
+	if w := serve(lib, "POST", "/lib/2"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /lib/2 on the merged router = %d, want 405", w.Code)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	h := write("")
+	tests := []struct {
+		name          string
+		have, another func(*Router)
+	}{
+		{"fixed", func(r *Router) { r.HandleFunc("/a", h) }, func(r *Router) { r.HandleFunc("/a", h) }},
+		{"params", func(r *Router) { r.HandleFunc("/a/<id>", h) }, func(r *Router) { r.HandleFunc("/a/<x>", h) }},
+		{"method", func(r *Router) { r.HandleMethod("GET", "/a", h) }, func(r *Router) { r.HandleMethod("POST", "/a", h) }},
+		{"guarded", func(r *Router) { r.HandleCookie("/a", "c", "", h) }, func(r *Router) { r.HandleCookie("/a", "c", "", h) }},
+		{"content type", func(r *Router) { r.HandleContentType("/a", "text/plain", h) }, func(r *Router) { r.HandleContentType("/a", "text/csv", h) }},
+	}
+	for _, tt := range tests {
+		rtr, other := NewRouter(), NewRouter()
+		tt.have(rtr)
+		tt.another(other)
+		other.HandleFunc("/new", h)
+		if err := rtr.Merge(other); err == nil {
+			t.Errorf("%s: Merge of a route that exists returned no error", tt.name)
+		}
+		if w := serve(rtr, "GET", "/new"); w.Code != http.StatusNotFound {
+			t.Errorf("%s: failed Merge added /new", tt.name)
+		}
+	}
+}
+
+func TestSetRouteEnabled(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/fixed", write("fixed"))
+	rtr.HandleFunc("/users/<id>", write("user"))
+	rtr.HandleCookie("/beta", "beta", "", write("beta"))
+	rtr.HandleSubtree("/docs", write("docs"))
+	rtr.Mount("/api", write("api"))
+	tests := []struct{ pattern, path string }{
+		{"/fixed", "/fixed"},
+		{"/users/<id>", "/users/1"},
+		{"/beta", "/beta"},
+		{"/docs", "/docs/intro"},
+		{"/api/", "/api/users"},
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.AddCookie(&http.Cookie{Name: "beta", Value: "1"})
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		return w
+	}
+	for _, tt := range tests {
+		if err := rtr.SetRouteEnabled(tt.pattern, false); err != nil {
+			t.Errorf("disable %s: %v", tt.pattern, err)
+			continue
+		}
+		if w := get(tt.path); w.Code != http.StatusNotFound {
+			t.Errorf("%s disabled: GET %s = %d, want 404", tt.pattern, tt.path, w.Code)
+		}
+		if err := rtr.SetRouteEnabled(tt.pattern, true); err != nil {
+			t.Errorf("enable %s: %v", tt.pattern, err)
+		}
+		if w := get(tt.path); w.Code != http.StatusOK {
+			t.Errorf("%s enabled again: GET %s = %d, want 200", tt.pattern, tt.path, w.Code)
+		}
+	}
+	if err := rtr.SetRouteEnabled("/missing", false); err == nil {
+		t.Error("disabling a route that isn't registered returned no error")
+	}
+}
+
+func TestLiteralPrefixFlags(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("(?i)^/api/<id>$", write("api"))
+	rtr.HandleFunc("(?i)/users", write("users"))
+	rtr.HandleFunc("^/exact/x", write("exact"))
+	tests := map[string]string{
+		"/api/5":       "api",
+		"/API/5":       "api",
+		"/Api/5":       "api",
+		"/x/USERS":     "users",
+		"/exact/x":     "exact",
+		"/other/api/5": "",
+	}
+	for path, want := range tests {
+		w := serve(rtr, "GET", path)
+		if want == "" && w.Code != http.StatusNotFound || want != "" && w.Body.String() != want {
+			t.Errorf("GET %s = %d %q, want %q", path, w.Code, w.Body, want)
+		}
+	}
+}
+
+func benchmarkSamePrefix(b *testing.B, anchor string) {
+	rtr := NewRouter()
+	for i := 0; i < 100; i++ {
+		rtr.HandleFunc(fmt.Sprintf("%s/api/v1/res%d/<id>$", anchor, i), func(w http.ResponseWriter, r *http.Request) {})
+	}
+	rtr.HandleFunc(anchor+"/other/<id>$", func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest("GET", "/other/5", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.URL.RawQuery = ""
+		rtr.ServeHTTP(w, r)
+	}
+}
+
+// 100 routes sharing a prefix the path doesn't have, and the one it matches
+func BenchmarkSamePrefix(b *testing.B)           { benchmarkSamePrefix(b, "^") }
+func BenchmarkSamePrefixUnanchored(b *testing.B) { benchmarkSamePrefix(b, "") }
+
+func BenchmarkRegister1000(b *testing.B) {
+	patterns := make([]string, 1000)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("/r%d/<id>/items/<item:int>", i)
+	}
+	f := func(w http.ResponseWriter, r *http.Request) {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rtr := NewRouter()
+		for _, p := range patterns {
+			if err := rtr.HandleFunc(p, f); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Println(v ...interface{}) {}
+
+func benchmarkLog(b *testing.B, log bool) {
+	rtr := NewRouter()
+	rtr.Log = log
+	rtr.Logger = discardLogger{}
+	rtr.HandleFunc("/users/<id>", func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest("GET", "/users/5", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.URL.RawQuery = ""
+		rtr.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkLogOff(b *testing.B) { benchmarkLog(b, false) }
+func BenchmarkLogOn(b *testing.B)  { benchmarkLog(b, true) }
+
+func TestRegexFirst(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a", write("fixed"))
+	rtr.HandleFunc("^/a$", write("regexp"))
+	if got := serve(rtr, "GET", "/a").Body.String(); got != "fixed" {
+		t.Errorf("GET /a = %q, want the fixed route", got)
+	}
+	rtr.RegexFirst = true
+	if got := serve(rtr, "GET", "/a").Body.String(); got != "regexp" {
+		t.Errorf("GET /a with RegexFirst = %q, want the regexp route", got)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	rtr := NewRouter()
+	rtr.RetryAfter = 90 * time.Second
+	rtr.HandleFunc("/a", write("a"))
+	rtr.HandleFunc("/health", write("ok"))
+	rtr.Maintenance(true, "/health")
+	w := serve(rtr, "GET", "/a")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "90" {
+		t.Errorf("GET /a in maintenance = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(rtr, "GET", "/missing"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /missing in maintenance = %d, want 503", w.Code)
+	}
+	if w := serve(rtr, "GET", "/health"); w.Code != http.StatusOK {
+		t.Errorf("exempt path in maintenance = %d, want 200", w.Code)
+	}
+	rtr.Maintenance(false)
+	if w := serve(rtr, "GET", "/a"); w.Code != http.StatusOK {
+		t.Errorf("GET /a after maintenance = %d, want 200", w.Code)
+	}
+}
+
+func TestMaintenanceWhileServing(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a", write("a"))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if w := serve(rtr, "GET", "/a"); w.Code != http.StatusOK && w.Code != http.StatusServiceUnavailable {
+					t.Errorf("status %d", w.Code)
+				}
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		rtr.Maintenance(j%2 == 0, "/health")
+	}
+	wg.Wait()
+}
+
+type lines []string
+
+func (l *lines) Println(v ...interface{}) { *l = append(*l, fmt.Sprint(v...)) }
+
+func TestOverlap(t *testing.T) {
+	h := write("")
+	orders := []struct {
+		name            string
+		first, overlaps string
+	}{
+		{"fixed then regexp", "/foo", "/fo+$"},
+		{"regexp then fixed", "/fo+$", "/foo"},
+	}
+	for _, tt := range orders {
+		rtr := NewRouter()
+		rtr.StrictRoutes = true
+		if err := rtr.HandleFunc(tt.first, h); err != nil {
+			t.Fatal(err)
+		}
+		if err := rtr.HandleFunc(tt.overlaps, h); err == nil {
+			t.Errorf("%s: no error with StrictRoutes", tt.name)
+		}
+
+		rtr = NewRouter()
+		var logged lines
+		rtr.Log = true
+		rtr.Logger = &logged
+		rtr.HandleFunc(tt.first, h)
+		if err := rtr.HandleFunc(tt.overlaps, h); err != nil {
+			t.Errorf("%s: error without StrictRoutes: %v", tt.name, err)
+		}
+		if len(logged) != 1 || !strings.HasPrefix(logged[0], "Route overlaps: ") {
+			t.Errorf("%s: logged %q, want the overlap", tt.name, logged)
+		}
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("js"), 0644)
+	rtr := NewRouter()
+	rtr.SPAFallback(filepath.Join(dir, "index.html"))
+	rtr.Static("/static", dir)
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		return w
+	}
+	if w := get("/app/users", "text/html,*/*"); w.Code != http.StatusOK || w.Body.String() != "<html>app</html>" {
+		t.Errorf("client route = %d %q, want the index file", w.Code, w.Body)
+	}
+	if w := get("/api/users", "application/json"); w.Code != http.StatusNotFound {
+		t.Errorf("API path = %d, want 404", w.Code)
+	}
+	if w := get("/static/app.js", "text/html"); w.Body.String() != "js" {
+		t.Errorf("static file = %q, want the file not the index", w.Body)
+	}
+}
+
+func TestParseDuplicateKeys(t *testing.T) {
+	rtr := NewRouter()
+	var vars map[string]string
+	var rest map[string][]string
+	var form url.Values
+	rtr.HandleFunc("/u/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		vars, rest = Parse(r)
+		form = url.Values{}
+		for k, v := range r.Form {
+			form[k] = append([]string(nil), v...)
+		}
+		Parse(r)
+		if !reflect.DeepEqual(r.Form, form) {
+			t.Errorf("Parse changed r.Form from %v to %v", form, r.Form)
+		}
+	})
+
+	serve(rtr, "GET", "/u/5?id=6&id=7&q=1")
+	if vars["id"] != "5" || !reflect.DeepEqual(rest, map[string][]string{"id": {"6", "7"}}) {
+		t.Errorf("GET: vars %v, rest %v", vars, rest)
+	}
+	if !reflect.DeepEqual(form["id"], []string{"5", "6", "7"}) {
+		t.Errorf("GET: r.Form id = %q", form["id"])
+	}
+
+	r := httptest.NewRequest("POST", "/u/5?id=9", strings.NewReader("id=8&id=5&x=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rtr.ServeHTTP(httptest.NewRecorder(), r)
+	if vars["id"] != "5" || !reflect.DeepEqual(rest, map[string][]string{"id": {"8", "5", "9"}, "x": {"1"}}) {
+		t.Errorf("POST: vars %v, rest %v", vars, rest)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a", write("a"))
+	rtr.HandleFunc("/healthz", write("ok"))
+	rtr.Draining(true, "/healthz")
+	w := serve(rtr, "GET", "/a")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Connection") != "close" {
+		t.Errorf("GET /a while draining = %d, Connection %q", w.Code, w.Header().Get("Connection"))
+	}
+	if w := serve(rtr, "GET", "/healthz"); w.Code != http.StatusOK || w.Header().Get("Connection") != "" {
+		t.Errorf("exempt path while draining = %d, Connection %q", w.Code, w.Header().Get("Connection"))
+	}
+	rtr.Draining(false)
+	if w := serve(rtr, "GET", "/a"); w.Code != http.StatusOK {
+		t.Errorf("GET /a after draining = %d, want 200", w.Code)
+	}
+}
+
+func TestHandleSubtree(t *testing.T) {
+	rtr := NewRouter()
+	var sub string
+	rtr.HandleSubtree("/docs", func(w http.ResponseWriter, r *http.Request) {
+		sub = Param(r, SubtreeParam)
+		w.Write([]byte("tree"))
+	})
+	rtr.HandleFunc("/docs/special", write("special"))
+	rtr.HandleFunc("/docs/v<n:int>$", write("version"))
+	tests := []struct{ path, body, sub string }{
+		{"/docs", "tree", ""},
+		{"/docs/", "tree", ""},
+		{"/docs/intro", "tree", "intro"},
+		{"/docs/a/b", "tree", "a/b"},
+		{"/docs/special", "special", ""},
+		{"/docs/v2", "version", ""},
+	}
+	for _, tt := range tests {
+		sub = ""
+		if got := serve(rtr, "GET", tt.path).Body.String(); got != tt.body || sub != tt.sub {
+			t.Errorf("GET %s = %q with %s %q, want %q %q", tt.path, got, SubtreeParam, sub, tt.body, tt.sub)
+		}
+	}
+	if w := serve(rtr, "GET", "/docsx"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /docsx = %d, want 404", w.Code)
+	}
+}
+
+func TestParseMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "hi")
+	mw.WriteField("id", "4")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("data"))
+	mw.Close()
+
+	rtr := NewRouter()
+	var vars map[string]string
+	var rest map[string][]string
+	var err error
+	var filename string
+	rtr.HandleFunc("/up/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		vars, rest, err = ParseMultipart(r, 1<<20)
+		if err == nil {
+			filename = r.MultipartForm.File["file"][0].Filename
+		}
+	})
+	r := httptest.NewRequest("POST", "/up/3", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rtr.ServeHTTP(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["id"] != "3" || !reflect.DeepEqual(rest, map[string][]string{"title": {"hi"}, "id": {"4"}}) {
+		t.Errorf("vars %v, rest %v", vars, rest)
+	}
+	if filename != "a.txt" {
+		t.Errorf("file %q, want a.txt", filename)
+	}
+
+	serve(rtr, "POST", "/up/3")
+	if err == nil || vars["id"] != "3" {
+		t.Errorf("without a multipart body: vars %v, err %v", vars, err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/new/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new " + Param(r, "id")))
+	})
+	rtr.HandleFunc("/gone", write("not reached"))
+	rtr.Rewrite = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/old/") {
+			r.URL.Path = "/new/" + strings.TrimPrefix(r.URL.Path, "/old/")
+		}
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return false
+		}
+		return true
+	}
+	if got := serve(rtr, "GET", "/old/7").Body.String(); got != "new 7" {
+		t.Errorf("GET /old/7 = %q, want the rewritten route", got)
+	}
+	if w := serve(rtr, "GET", "/gone"); w.Code != http.StatusGone || w.Body.Len() != 0 {
+		t.Errorf("GET /gone = %d %q, want Rewrite's 410", w.Code, w.Body)
+	}
+}
+
+type greeter struct{ greeting string }
+
+func (g greeter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(g.greeting + " " + Param(r, "name")))
+}
+
+func TestHandle(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.Handle("/hi/<name>$", greeter{"hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "GET", "/hi/bob").Body.String(); got != "hello bob" {
+		t.Errorf("GET /hi/bob = %q", got)
+	}
+	if err := rtr.Handle("/hi/<other>$", greeter{}); err == nil {
+		t.Error("Handle of a registered pattern returned no error")
+	}
+}
+
+func TestSkipCancelled(t *testing.T) {
+	rtr := NewRouter()
+	called := false
+	rtr.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) { called = true })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil).WithContext(ctx))
+		return w
+	}
+
+	cancelled()
+	if !called {
+		t.Error("handler not called for a cancelled request without SkipCancelled")
+	}
+	called = false
+	rtr.SkipCancelled = true
+	if w := cancelled(); w.Code != StatusClientClosedRequest || called {
+		t.Errorf("cancelled request = %d, handler called %v", w.Code, called)
+	}
+	if serve(rtr, "GET", "/a"); !called {
+		t.Error("handler not called for a live request")
+	}
+}
+
+func TestReset(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Strip = true
+	rtr.NotFound = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }
+	rtr.HandleFunc("/a", write("a"))
+	rtr.HandleFunc("/b/<id>", write("b"))
+	rtr.HandleMethod("GET", "/m", write("m"))
+	rtr.HandleCookie("/c", "c", "", write("c"))
+	rtr.Host("example.com").HandleFunc("/h", write("h"))
+	rtr.HandleFunc("/off", write("off"))
+	rtr.SetRouteEnabled("/off", false)
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			serve(rtr, "GET", "/b/1")
+			serve(rtr, "GET", "/m")
+		}
+		done <- true
+	}()
+	for i := 0; i < 20; i++ {
+		rtr.Reset()
+		rtr.HandleFunc("/b/<id>", write("b"))
+		rtr.HandleMethod("GET", "/m", write("m"))
+	}
+	<-done
+
+	rtr.Reset()
+	for _, path := range []string{"/a", "/b/1", "/m", "/c", "/off"} {
+		if w := serve(rtr, "GET", path); w.Code != http.StatusTeapot {
+			t.Errorf("GET %s after Reset = %d, want NotFound", path, w.Code)
+		}
+	}
+	if w := serveHost(rtr, "example.com", "/h"); w.Code != http.StatusTeapot {
+		t.Errorf("host route after Reset = %d, want NotFound", w.Code)
+	}
+	if !rtr.Strip {
+		t.Error("Reset cleared Strip")
+	}
+	if err := rtr.HandleMethod("GET", "/m", write("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rtr.HandleFunc("/off", write("off")); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "GET", "/m/").Body.String(); got != "new" {
+		t.Errorf("GET /m/ registered after Reset = %q", got)
+	}
+	if got := serve(rtr, "GET", "/off").Body.String(); got != "off" {
+		t.Errorf("route disabled before Reset and registered again = %q", got)
+	}
+}
+
+func TestHandleCanary(t *testing.T) {
+	canaryIntn = rand.New(rand.NewSource(1)).Intn
+	defer func() { canaryIntn = rand.Intn }()
+	rtr := NewRouter()
+	counts := map[string]int{}
+	count := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { counts[name]++ }
+	}
+	rtr.HandleCanary("/ten", count("stable"), count("canary"), 10)
+	for i := 0; i < 1000; i++ {
+		serve(rtr, "GET", "/ten")
+	}
+	if counts["canary"] < 70 || counts["canary"] > 130 || counts["stable"]+counts["canary"] != 1000 {
+		t.Errorf("10%% canary: %v", counts)
+	}
+
+	rtr.HandleCanary("/none", count("stable none"), count("canary none"), -5)
+	rtr.HandleCanary("/all", count("stable all"), count("canary all"), 150)
+	for i := 0; i < 100; i++ {
+		serve(rtr, "GET", "/none")
+		serve(rtr, "GET", "/all")
+	}
+	if counts["canary none"] != 0 || counts["stable all"] != 0 {
+		t.Errorf("percent not clamped: %v", counts)
+	}
+}
+
+func TestHandleSelect(t *testing.T) {
+	rtr := NewRouter()
+	selector := func(r *http.Request) int {
+		var i int
+		fmt.Sscan(r.URL.Query().Get("i"), &i)
+		return i
+	}
+	rtr.HandleSelect("/s", selector, write("zero"), write("one"))
+	for path, want := range map[string]string{"/s?i=0": "zero", "/s?i=1": "one"} {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+	for _, path := range []string{"/s?i=2", "/s?i=-1"} {
+		if w := serve(rtr, "GET", path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+	}
+	reasons := []NoMatchReason{}
+	rtr.NoMatch = func(w http.ResponseWriter, r *http.Request, reason NoMatchReason) {
+		reasons = append(reasons, reason)
+		w.WriteHeader(http.StatusTeapot)
+	}
+	for _, path := range []string{"/s?i=2", "/s?i=-1"} {
+		if w := serve(rtr, "GET", path); w.Code != http.StatusTeapot {
+			t.Errorf("GET %s with NoMatch = %d, want 418", path, w.Code)
+		}
+	}
+	if len(reasons) != 2 || reasons[0] != ReasonNotFound || reasons[1] != ReasonNotFound {
+		t.Errorf("NoMatch reasons = %v", reasons)
+	}
+}
+
+func TestHandleFuncf(t *testing.T) {
+	rtr := NewRouter()
+	for i := 1; i <= 3; i++ {
+		i := i
+		err := rtr.HandleFuncf("/v%d/<id>$", []interface{}{i}, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, i, " ", Param(r, "id"))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		if got, want := serve(rtr, "GET", fmt.Sprintf("/v%d/a", i)).Body.String(), fmt.Sprint(i, " a"); got != want {
+			t.Errorf("GET /v%d/a = %q, want %q", i, got, want)
+		}
+	}
+	if err := rtr.HandleFuncf("/v%d", nil, write("")); err == nil {
+		t.Error("missing argument registered")
+	}
+	if err := rtr.HandleFuncf("/v%d", []interface{}{1, 2}, write("")); err == nil {
+		t.Error("extra argument registered")
+	}
+	if err := rtr.HandleFuncf("x%s", []interface{}{"y"}, write("")); err == nil {
+		t.Error("pattern without a leading / registered")
+	}
+}
+
+func TestPatternFlags(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleFunc("(?i)^/Users$", write("users")); err != nil {
+		t.Fatal(err)
+	}
+	err := rtr.HandleFunc("(?i)^/Items/<id>$", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Param(r, "id")))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"/users":    "users",
+		"/uSERS":    "users",
+		"/ITEMS/Ab": "Ab",
+		"/items/x":  "x",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s = %q, want %q", path, got, want)
+		}
+	}
+	if w := serve(rtr, "GET", "/usersx"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /usersx = %d, want 404", w.Code)
+	}
+}
+
+func TestUseEscapedPath(t *testing.T) {
+	rtr := NewRouter()
+	var name string
+	rtr.HandleFunc("/files/<name>$", func(w http.ResponseWriter, r *http.Request) { name = Param(r, "name") })
+	rtr.HandleFunc("/a b", write("space"))
+	if w := serve(rtr, "GET", "/files/a%2Fb"); w.Code != http.StatusNotFound {
+		t.Errorf("%%2F without UseEscapedPath = %d, want 404 as it is a /", w.Code)
+	}
+	if got := serve(rtr, "GET", "/a%20b").Body.String(); got != "space" {
+		t.Errorf("escaped fixed route without UseEscapedPath = %q", got)
+	}
+	rtr.UseEscapedPath = true
+	if w := serve(rtr, "GET", "/files/a%2Fb"); w.Code != http.StatusOK || name != "a%2Fb" {
+		t.Errorf("%%2F with UseEscapedPath = %d with name %q", w.Code, name)
+	}
+}
+
+func TestMaxHeaders(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/", write("ok"))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("A", "1")
+	r.Header.Add("A", "2")
+	w := httptest.NewRecorder()
+	rtr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("without MaxHeaders = %d", w.Code)
+	}
+	rtr.MaxHeaders = 2
+	w = httptest.NewRecorder()
+	rtr.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("at MaxHeaders = %d, want 200", w.Code)
+	}
+	r.Header.Add("B", "1")
+	w = httptest.NewRecorder()
+	rtr.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("over MaxHeaders = %d, want 431", w.Code)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/normal", write("normal"))
+	rtr.Matcher = func(method, path string) (http.HandlerFunc, map[string]string, bool) {
+		if method != "GET" || !strings.HasPrefix(path, "/dsl:") {
+			return nil, nil, false
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(Param(r, "x")))
+		}, map[string]string{"x": path[len("/dsl:"):]}, true
+	}
+	if got := serve(rtr, "GET", "/dsl:abc").Body.String(); got != "abc" {
+		t.Errorf("GET /dsl:abc = %q, want the Matcher's variable", got)
+	}
+	for _, tt := range []struct{ method, path string }{{"GET", "/normal"}, {"POST", "/dsl:a"}} {
+		if w := serve(rtr, tt.method, tt.path); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s with a Matcher = %d, want 404", tt.method, tt.path, w.Code)
+		}
+	}
+	rtr.Matcher = nil
+	if got := serve(rtr, "GET", "/normal").Body.String(); got != "normal" {
+		t.Errorf("GET /normal without a Matcher = %q", got)
+	}
+}
+
+func TestPatternQuery(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleFunc("/foo?x=1", write("foo")); err == nil {
+		t.Error("pattern with a query registered")
+	}
+	if err := rtr.HandleFunc("/colou?r", write("colour")); err != nil {
+		t.Errorf("regexp with ? rejected: %v", err)
+	}
+	rtr.StripPatternQuery = true
+	if err := rtr.HandleFunc("/foo?x=1", write("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(rtr, "GET", "/foo?x=2").Body.String(); got != "foo" {
+		t.Errorf("GET /foo?x=2 = %q, want the stripped pattern", got)
+	}
+	rtr.HandleMethod("GET", "/q?x=1", write("get"))
+	rtr.HandleMethod("POST", "/q", write("post"))
+	if got := rtr.AllowedMethods("/q"); strings.Join(got, ",") != "GET,HEAD,POST" {
+		t.Errorf("AllowedMethods(/q) = %q, want the methods of the stripped pattern", got)
+	}
+	if got := serve(rtr, "POST", "/q").Body.String(); got != "post" {
+		t.Errorf("POST /q = %q", got)
+	}
+}
+
+func TestHandleGone(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleGone("/old/<id>"); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(rtr, "GET", "/old/1"); w.Code != http.StatusGone {
+		t.Errorf("GET /old/1 = %d, want 410", w.Code)
+	}
+	if w := serve(rtr, "GET", "/new"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /new = %d, want 404", w.Code)
+	}
+}
+
+func TestHandleRedirect(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleRedirect("/u/<id>$", "/users/<id>/profile", http.StatusMovedPermanently); err != nil {
+		t.Fatal(err)
+	}
+	if err := rtr.HandleRedirect("/old", "/new", http.StatusFound); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/u/5", http.StatusMovedPermanently, "/users/5/profile"},
+		{"/u/a%20b", http.StatusMovedPermanently, "/users/a%20b/profile"},
+		{"/old", http.StatusFound, "/new"},
+	}
+	for _, tt := range tests {
+		w := serve(rtr, "GET", tt.path)
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s = %d to %q, want %d to %q", tt.path, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+	for _, code := range []int{http.StatusOK, http.StatusNotFound, 0} {
+		if err := rtr.HandleRedirect("/x", "/y", code); err == nil {
+			t.Errorf("redirect with code %d registered", code)
+		}
+	}
+}
+
+func TestHandleAliases(t *testing.T) {
+	rtr := NewRouter()
+	path := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.URL.Path)) }
+	if err := rtr.HandleAliases([]string{"/color", "/colour"}, path); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/color", "/colour"} {
+		if got := serve(rtr, "GET", p).Body.String(); got != p {
+			t.Errorf("GET %s = %q", p, got)
+		}
+	}
+	err := rtr.HandleAliases([]string{"/color", "/hue", "/colour"}, path)
+	if err == nil || !strings.Contains(err.Error(), "/color") || !strings.Contains(err.Error(), "/colour") {
+		t.Errorf("error %v, want both registered aliases in it", err)
+	}
+	if got := serve(rtr, "GET", "/hue").Body.String(); got != "/hue" {
+		t.Errorf("alias after a failing one = %q, want it registered", got)
+	}
+}
+
+func TestExactSlash(t *testing.T) {
+	rtr := NewRouter()
+	rtr.Strip = true
+	rtr.ExactSlash = true
+	rtr.HandleFunc("/foo", write("item"))
+	rtr.HandleFunc("/foo/", write("list"))
+	rtr.HandleFunc("/bar", write("bar"))
+	tests := map[string]string{
+		"/foo":  "item",
+		"/foo/": "list",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Body.String(); got != want {
+			t.Errorf("GET %s with ExactSlash = %q, want %q", path, got, want)
+		}
+	}
+	if w := serve(rtr, "GET", "/bar/"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /bar/ with ExactSlash = %d, want 404 as Strip has no effect", w.Code)
+	}
+	rtr.ExactSlash = false
+	if got := serve(rtr, "GET", "/foo/").Body.String(); got != "item" {
+		t.Errorf("GET /foo/ without ExactSlash = %q, want it stripped", got)
+	}
+}
+
+func TestHandleNoContent(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleNoContent("/beacon"); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{"GET", "POST"} {
+		w := serve(rtr, method, "/beacon")
+		if w.Code != http.StatusNoContent || w.Body.Len() != 0 || w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s /beacon = %d %q, Cache-Control %q", method, w.Code, w.Body, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestInvalidRegexp(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleFunc("/a/(b", write("")); err == nil {
+		t.Error("invalid regexp registered")
+	}
+	if err := rtr.HandleFunc("/u/<id>/(b", write("")); err == nil {
+		t.Error("invalid regexp with a variable registered")
+	}
+	always := func(*http.Request) bool { return true }
+	if err := rtr.HandleWhen("/g/(b", always, write("")); err == nil {
+		t.Error("invalid guarded regexp registered")
+	}
+}
@@ -0,0 +1,154 @@
+package yar
+
+import (
+	"sort"
+	"strings"
+)
+
+// RouteInfo describes a registered route, as returned by ListRoutes
+type RouteInfo struct {
+	Pattern string `json:"pattern"` // the key the route is stored under
+	// the pattern as registered, e.g. "/users/<id:int>", if it had variables
+	Declared string   `json:"declared,omitempty"`
+	Kind     string   `json:"kind"` // "fixed", "regexp", "params", "subtree" or "guarded"
+	Methods  []string `json:"methods,omitempty"`
+	Host     string   `json:"host,omitempty"`
+}
+
+// ListRoutes returns the registered routes, including those of hosts. They
+// are in the order they are checked: guarded routes, fixed routes (sorted)
+// and then the regexp routes, unless RegexFirst is set.
+func (rtr *Router) ListRoutes() []RouteInfo {
+	routes := rtr.listRoutes("")
+	rtr.mu.RLock()
+	hosts := make([]string, 0, len(rtr.hosts))
+	for h := range rtr.hosts {
+		hosts = append(hosts, h)
+	}
+	subs := rtr.hosts
+	rtr.mu.RUnlock()
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		routes = append(routes, subs[h].listRoutes(h)...)
+	}
+	return routes
+}
+
+// Len returns the number of routes, not counting those of hosts
+func (rtr *Router) Len() int {
+	fixed, regexps := rtr.Counts()
+	return fixed + regexps
+}
+
+// Counts returns the number of fixed routes and of regexp routes, which
+// include routes with variables, subtrees and guarded routes
+func (rtr *Router) Counts() (fixed, regexps int) {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	return len(rtr.FixedRoutes), len(rtr.Routes) + len(rtr.guarded)
+}
+
+func (rtr *Router) listRoutes(host string) []RouteInfo {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	info := func(pattern, declared, kind string) RouteInfo {
+		ri := RouteInfo{Pattern: pattern, Declared: declared, Kind: kind, Host: host}
+		if mr, ok := rtr.methods[pattern]; ok {
+			for m := range mr.handlers {
+				ri.Methods = append(ri.Methods, m)
+			}
+			sort.Strings(ri.Methods)
+		}
+		return ri
+	}
+	routes := []RouteInfo{}
+	for _, rr := range rtr.guarded {
+		routes = append(routes, info(rr.Pattern.String(), rr.declared, "guarded"))
+	}
+	fixed := make([]string, 0, len(rtr.FixedRoutes))
+	for p := range rtr.FixedRoutes {
+		fixed = append(fixed, p)
+	}
+	sort.Strings(fixed)
+	regexps := []RouteInfo{}
+	for _, rr := range rtr.Routes {
+		kind := "regexp"
+		if rr.subtree {
+			kind = "subtree"
+		} else if rr.params != nil {
+			kind = "params"
+		}
+		regexps = append(regexps, info(rr.Pattern.String(), rr.declared, kind))
+	}
+	if rtr.RegexFirst {
+		routes = append(routes, regexps...)
+	}
+	for _, p := range fixed {
+		routes = append(routes, info(p, "", "fixed"))
+	}
+	if !rtr.RegexFirst {
+		routes = append(routes, regexps...)
+	}
+	return routes
+}
+
+// RouteDiff is the difference between two route tables, see Diff
+type RouteDiff struct {
+	Added   []RouteInfo   `json:"added,omitempty"`
+	Removed []RouteInfo   `json:"removed,omitempty"`
+	Changed []RouteChange `json:"changed,omitempty"`
+}
+
+// RouteChange is a route in both tables that was declared differently or
+// has other methods
+type RouteChange struct {
+	Old RouteInfo `json:"old"`
+	New RouteInfo `json:"new"`
+}
+
+// Empty reports if the tables had the same routes
+func (d RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the routes of rtr to those of other, e.g. the routes of the
+// last release to those of this build, to catch routes removed by accident.
+// Routes are told apart by host, kind and pattern, and each list is sorted
+// in that order. Guarded routes registered several times for the same
+// pattern count as one.
+func (rtr *Router) Diff(other *Router) RouteDiff {
+	key := func(ri RouteInfo) string {
+		return ri.Host + "\x00" + ri.Kind + "\x00" + ri.Pattern
+	}
+	index := func(routes []RouteInfo) (map[string]RouteInfo, []string) {
+		m := map[string]RouteInfo{}
+		keys := []string{}
+		for _, ri := range routes {
+			k := key(ri)
+			if _, ok := m[k]; !ok {
+				keys = append(keys, k)
+			}
+			m[k] = ri
+		}
+		sort.Strings(keys)
+		return m, keys
+	}
+	old, oldKeys := index(rtr.ListRoutes())
+	cur, curKeys := index(other.ListRoutes())
+	var d RouteDiff
+	for _, k := range oldKeys {
+		o := old[k]
+		n, ok := cur[k]
+		if !ok {
+			d.Removed = append(d.Removed, o)
+		} else if o.Declared != n.Declared || strings.Join(o.Methods, ",") != strings.Join(n.Methods, ",") {
+			d.Changed = append(d.Changed, RouteChange{o, n})
+		}
+	}
+	for _, k := range curKeys {
+		if _, ok := old[k]; !ok {
+			d.Added = append(d.Added, cur[k])
+		}
+	}
+	return d
+}
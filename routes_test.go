@@ -0,0 +1,65 @@
+package yar
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCounts(t *testing.T) {
+	rtr := NewRouter()
+	if rtr.Len() != 0 {
+		t.Errorf("Len of a new router = %d", rtr.Len())
+	}
+	rtr.HandleFunc("/a", write(""))
+	rtr.HandleFunc("/b", write(""))
+	rtr.HandleFunc("/u/<id>", write(""))
+	rtr.HandleWhen("/a", func(*http.Request) bool { return true }, write(""))
+	rtr.Host("x.com").HandleFunc("/h", write(""))
+	fixed, regexps := rtr.Counts()
+	if fixed != 2 || regexps != 2 || rtr.Len() != 4 {
+		t.Errorf("Counts = %d, %d and Len = %d, want 2, 2 and 4", fixed, regexps, rtr.Len())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	h := write("")
+	old, cur := NewRouter(), NewRouter()
+	for _, p := range []string{"/keep", "/gone", "/z", "/old/<id>"} {
+		old.HandleFunc(p, h)
+	}
+	for _, p := range []string{"/keep", "/new", "/added", "/b", "/a"} {
+		cur.HandleFunc(p, h)
+	}
+	old.HandleMethod("GET", "/m", h)
+	cur.HandleMethod("GET", "/m", h)
+	cur.HandleMethod("POST", "/m", h)
+	cur.Host("x.com").HandleFunc("/h", h)
+
+	d := old.Diff(cur)
+	removed := []string{}
+	for _, ri := range d.Removed {
+		removed = append(removed, ri.Kind+" "+ri.Pattern)
+	}
+	added := []string{}
+	for _, ri := range d.Added {
+		added = append(added, ri.Host+" "+ri.Pattern)
+	}
+	if want := []string{"fixed /gone", "fixed /z", "params /old/([^/]+)"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	if want := []string{" /a", " /added", " /b", " /new", "x.com /h"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added %q, want %q", added, want)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].New.Pattern != "/m" || !reflect.DeepEqual(d.Changed[0].New.Methods, []string{"GET", "POST"}) {
+		t.Errorf("changed %+v", d.Changed)
+	}
+	for i := 0; i < 20; i++ {
+		if again := old.Diff(cur); !reflect.DeepEqual(again, d) {
+			t.Fatalf("Diff not deterministic: %+v then %+v", d, again)
+		}
+	}
+	if !old.Diff(old).Empty() || d.Empty() {
+		t.Error("Empty is wrong")
+	}
+}
@@ -0,0 +1,71 @@
+package yar
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// sampleSize is how many samples RecentSamples keeps
+const sampleSize = 256
+
+// Sample is a request recorded by Sample
+type Sample struct {
+	Method  string
+	Pattern string // key of the route that matched, "" if none did
+	Time    time.Time
+}
+
+// sampler is a fixed size ring of samples that can be written to and read
+// from concurrently
+type sampler struct {
+	rate    atomic.Uint64 // math.Float64bits of the rate
+	next    atomic.Uint64
+	samples [sampleSize]atomic.Pointer[Sample]
+}
+
+// Sample records the method and route of a fraction rate (0 to 1) of
+// requests, the last ones can be read with RecentSamples. It is meant as a
+// cheap way to see where traffic goes, a rate of 0 stops sampling. It is
+// safe to call while the router is serving.
+func (rtr *Router) Sample(rate float64) {
+	s := rtr.sampler.Load()
+	if s == nil {
+		rtr.sampler.CompareAndSwap(nil, &sampler{})
+		s = rtr.sampler.Load()
+	}
+	s.rate.Store(math.Float64bits(rate))
+}
+
+// RecentSamples returns the samples recorded by Sample, oldest first. At
+// most the last 256 are kept.
+func (rtr *Router) RecentSamples() []Sample {
+	s := rtr.sampler.Load()
+	if s == nil {
+		return nil
+	}
+	next := s.next.Load()
+	samples := []Sample{}
+	for i := uint64(0); i < sampleSize; i++ {
+		if p := s.samples[(next+i)%sampleSize].Load(); p != nil {
+			samples = append(samples, *p)
+		}
+	}
+	return samples
+}
+
+// sample records r if it is picked at the sampling rate
+func (rtr *Router) sample(r *http.Request, pattern string) {
+	s := rtr.sampler.Load()
+	if s == nil {
+		return
+	}
+	rate := math.Float64frombits(s.rate.Load())
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+	i := s.next.Add(1) - 1
+	s.samples[i%sampleSize].Store(&Sample{Method: r.Method, Pattern: pattern, Time: time.Now()})
+}
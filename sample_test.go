@@ -0,0 +1,40 @@
+package yar
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a/<id>", write(""))
+	if got := rtr.RecentSamples(); got != nil {
+		t.Fatalf("RecentSamples before Sample = %v", got)
+	}
+	rtr.Sample(1)
+	for i := 0; i < sampleSize+44; i++ {
+		serve(rtr, "POST", fmt.Sprint("/a/", i))
+	}
+	serve(rtr, "GET", "/missing")
+	samples := rtr.RecentSamples()
+	if len(samples) != sampleSize {
+		t.Fatalf("%d samples, want %d", len(samples), sampleSize)
+	}
+	if first := samples[0]; first.Method != "POST" || first.Pattern == "" {
+		t.Errorf("oldest sample %+v", first)
+	}
+	if last := samples[sampleSize-1]; last.Method != "GET" || last.Pattern != "" {
+		t.Errorf("newest sample %+v, want the unmatched GET", last)
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time.Before(samples[i-1].Time) {
+			t.Fatalf("sample %d is older than the one before it", i)
+		}
+	}
+
+	rtr.Sample(0)
+	serve(rtr, "PUT", "/a/1")
+	if last := rtr.RecentSamples()[sampleSize-1]; last.Method != "GET" {
+		t.Errorf("sampled %+v at rate 0", last)
+	}
+}
@@ -0,0 +1,111 @@
+package yar
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// precompressed are the encodings Static looks for siblings of, in order of
+// preference, with the extension of the sibling
+var precompressed = []struct{ encoding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Static serves the files in dir under prefix, e.g. Static("/assets",
+// "public") serves public/app.js for /assets/app.js. See StaticFS.
+func (rtr *Router) Static(prefix, dir string) error {
+	return rtr.StaticFS(prefix, os.DirFS(dir))
+}
+
+// StaticFS serves the files of fsys under prefix with http.FileServer. If
+// ServePrecompressed is set and the client accepts it, a precompressed
+// sibling of a file (foo.js.br or foo.js.gz) is served instead with its
+// Content-Encoding, falling back to the file itself if there is none.
+func (rtr *Router) StaticFS(prefix string, fsys fs.FS) error {
+	files := http.FileServer(http.FS(fsys))
+	return rtr.Mount(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rtr.ServePrecompressed && servePrecompressed(w, r, fsys) {
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// servePrecompressed serves a precompressed sibling of the requested file
+// if the client accepts its encoding, it returns false if there is none
+func servePrecompressed(w http.ResponseWriter, r *http.Request, fsys fs.FS) bool {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+		return false
+	}
+	if fi, err := fs.Stat(fsys, name); err != nil || fi.IsDir() {
+		return false
+	}
+	for _, pc := range precompressed {
+		if !acceptsEncoding(r, pc.encoding) {
+			continue
+		}
+		f, err := fsys.Open(name + pc.ext)
+		if err != nil {
+			continue
+		}
+		fi, err := f.Stat()
+		rs, ok := f.(io.ReadSeeker)
+		if err != nil || fi.IsDir() || !ok {
+			f.Close()
+			continue
+		}
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ctype)
+		w.Header().Set("Content-Encoding", pc.encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+		http.ServeContent(w, r, name, fi.ModTime(), rs)
+		f.Close()
+		return true
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	return false
+}
+
+// acceptsEncoding reports if the Accept-Encoding of r allows encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), encoding) {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Favicon registers /favicon.ico to serve the file at path with headers that
+// let it be cached for a year. If path is "" it replies 204 No Content, so
+// browsers asking for it don't fill the logs with 404s.
+func (rtr *Router) Favicon(path string) error {
+	cache := CacheControl(365*24*time.Hour, true)
+	if path == "" {
+		return rtr.HandleFunc("/favicon.ico", cache(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	return rtr.HandleFunc("/favicon.ico", cache(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, path)
+	}))
+}
@@ -0,0 +1,83 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServePrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("plain js"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("GZ"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js.br"), []byte("BR"), 0644)
+	os.WriteFile(filepath.Join(dir, "other.css"), []byte("css"), 0644)
+	rtr := NewRouter()
+	rtr.ServePrecompressed = true
+	if err := rtr.Static("/assets", dir); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		rtr.ServeHTTP(w, r)
+		return w
+	}
+	tests := []struct{ path, acceptEncoding, body, encoding string }{
+		{"/assets/app.js", "gzip, deflate", "GZ", "gzip"},
+		{"/assets/app.js", "gzip, br", "BR", "br"},
+		{"/assets/app.js", "br;q=0, gzip", "GZ", "gzip"},
+		{"/assets/app.js", "identity", "plain js", ""},
+		{"/assets/other.css", "gzip", "css", ""},
+	}
+	for _, tt := range tests {
+		w := get(tt.path, tt.acceptEncoding)
+		if w.Body.String() != tt.body || w.Header().Get("Content-Encoding") != tt.encoding {
+			t.Errorf("GET %s with %q = %q in %q, want %q in %q", tt.path, tt.acceptEncoding,
+				w.Body, w.Header().Get("Content-Encoding"), tt.body, tt.encoding)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("GET %s with %q: Vary %q", tt.path, tt.acceptEncoding, w.Header().Get("Vary"))
+		}
+	}
+	if ct := get("/assets/app.js", "gzip").Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("precompressed Content-Type %q, want that of app.js", ct)
+	}
+	if w := get("/assets/nope.js", "gzip"); w.Code != http.StatusNotFound {
+		t.Errorf("missing file = %d, want 404", w.Code)
+	}
+	rtr.ServePrecompressed = false
+	if got := get("/assets/app.js", "gzip").Body.String(); got != "plain js" {
+		t.Errorf("without ServePrecompressed = %q", got)
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	icon := filepath.Join(t.TempDir(), "f.ico")
+	os.WriteFile(icon, []byte("ICO"), 0644)
+	rtr := NewRouter()
+	if err := rtr.Favicon(icon); err != nil {
+		t.Fatal(err)
+	}
+	w := serve(rtr, "GET", "/favicon.ico")
+	if w.Code != http.StatusOK || w.Body.String() != "ICO" {
+		t.Errorf("file favicon = %d %q", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000" {
+		t.Errorf("file favicon: Cache-Control %q", cc)
+	}
+
+	rtr = NewRouter()
+	rtr.Favicon("")
+	w = serve(rtr, "GET", "/favicon.ico")
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("204 favicon = %d %q", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000" {
+		t.Errorf("204 favicon: Cache-Control %q", cc)
+	}
+}
@@ -0,0 +1,37 @@
+package yar
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats are the totals counted while CountBytes is set
+type Stats struct {
+	RequestBytes  uint64 // bytes read from request bodies by handlers
+	ResponseBytes uint64 // bytes of response bodies written
+}
+
+type byteStats struct {
+	requestBytes  atomic.Uint64
+	responseBytes atomic.Uint64
+}
+
+// Stats returns the bytes counted since the router was created
+func (rtr *Router) Stats() Stats {
+	return Stats{
+		RequestBytes:  rtr.stats.requestBytes.Load(),
+		ResponseBytes: rtr.stats.responseBytes.Load(),
+	}
+}
+
+// countingBody counts the bytes read from a request body
+type countingBody struct {
+	io.ReadCloser
+	stats *byteStats
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.stats.requestBytes.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,39 @@
+package yar
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCountBytes(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(append(b, '!'))
+	})
+	post := func(body string) {
+		rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/echo", strings.NewReader(body)))
+	}
+	post("abc")
+	if st := rtr.Stats(); st != (Stats{}) {
+		t.Errorf("Stats without CountBytes = %+v", st)
+	}
+
+	rtr.CountBytes = true
+	var wg sync.WaitGroup
+	for _, body := range []string{"abc", "de", "fghij"} {
+		wg.Add(1)
+		go func(body string) {
+			defer wg.Done()
+			post(body)
+		}(body)
+	}
+	wg.Wait()
+	if st := rtr.Stats(); st.RequestBytes != 10 || st.ResponseBytes != 13 {
+		t.Errorf("Stats = %+v, want 10 bytes read and 13 written", st)
+	}
+}
@@ -0,0 +1,71 @@
+package yar
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type routerKey struct{}
+
+// NotFoundSuggestion returns the registered path closest to the one requested
+// for use in NotFound, e.g. to reply "did you mean /users?". It returns "" if
+// nothing is close or r didn't come through a Router.
+// Fixed routes are compared as they are and regexp routes by their literal
+// prefix.
+func NotFoundSuggestion(r *http.Request) string {
+	rtr, _ := r.Context().Value(routerKey{}).(*Router)
+	if rtr == nil {
+		return ""
+	}
+	path := rtr.stripPath(r.URL.Path)
+	candidates := []string{}
+	rtr.mu.RLock()
+	for p := range rtr.FixedRoutes {
+		candidates = append(candidates, p)
+	}
+	for _, rr := range rtr.Routes {
+		prefix, _ := rr.Pattern.LiteralPrefix()
+		if prefix = strings.TrimPrefix(prefix, "^"); len(prefix) > 1 {
+			candidates = append(candidates, prefix)
+		}
+	}
+	rtr.mu.RUnlock()
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		d := editDistance(path, c)
+		if d == 0 || d > len(c)/3+1 {
+			continue
+		}
+		if bestDist < 0 || d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// withRouter stores rtr in the context for NotFoundSuggestion
+func withRouter(r *http.Request, rtr *Router) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routerKey{}, rtr))
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,37 @@
+package yar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotFoundSuggestion(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/users", write(""))
+	rtr.HandleFunc("/orders", write(""))
+	rtr.HandleFunc("/api/items/<id>", write(""))
+	var suggested string
+	rtr.NotFound = func(w http.ResponseWriter, r *http.Request) {
+		suggested = NotFoundSuggestion(r)
+		http.NotFound(w, r)
+	}
+	tests := map[string]string{
+		"/user":                 "/users",
+		"/order":                "/orders",
+		"/api/itms/":            "/api/items/",
+		"/completely-different": "",
+	}
+	for path, want := range tests {
+		suggested = "-"
+		if w := serve(rtr, "GET", path); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, w.Code)
+		}
+		if suggested != want {
+			t.Errorf("GET %s: suggested %q, want %q", path, suggested, want)
+		}
+	}
+	if got := NotFoundSuggestion(httptest.NewRequest("GET", "/user", nil)); got != "" {
+		t.Errorf("suggestion outside a Router = %q, want none", got)
+	}
+}
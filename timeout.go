@@ -0,0 +1,111 @@
+package yar
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutResponse sets the status and body sent when a route registered
+// with HandleTimeout runs out of time, the default is a 503 with its status
+// text
+func (rtr *Router) TimeoutResponse(status int, body []byte) {
+	rtr.mu.Lock()
+	defer rtr.mu.Unlock()
+	rtr.timeoutStatus, rtr.timeoutBody = status, body
+}
+
+// HandleTimeout registers f for pattern with a time limit of d. f runs with
+// a request context that is done after d (see DeadlineRemaining), and its
+// response is buffered. If it doesn't return in time the client gets the
+// TimeoutResponse instead and what f writes afterwards is dropped, its
+// writes then return http.ErrHandlerTimeout. Unlike http.TimeoutHandler the
+// response is configurable.
+func (rtr *Router) HandleTimeout(pattern string, d time.Duration, f http.HandlerFunc) error {
+	return rtr.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+		tw := &bufferedTimeoutWriter{header: http.Header{}}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			f(tw, r)
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.body.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			status, body := rtr.timeoutReply()
+			w.WriteHeader(status)
+			w.Write(body)
+		}
+	})
+}
+
+func (rtr *Router) timeoutReply() (int, []byte) {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	if rtr.timeoutStatus == 0 {
+		return http.StatusServiceUnavailable, []byte(http.StatusText(http.StatusServiceUnavailable))
+	}
+	return rtr.timeoutStatus, rtr.timeoutBody
+}
+
+// bufferedTimeoutWriter holds the response of a handler until it returns,
+// the handler runs in its own goroutine so it is guarded by mu
+type bufferedTimeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (tw *bufferedTimeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *bufferedTimeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *bufferedTimeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
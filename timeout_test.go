@@ -0,0 +1,39 @@
+package yar
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHandleTimeout(t *testing.T) {
+	rtr := NewRouter()
+	lateErr := make(chan error, 2)
+	rtr.HandleTimeout("/slow", 20*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		time.Sleep(5 * time.Millisecond)
+		_, err := w.Write([]byte("late"))
+		lateErr <- err
+	})
+	rtr.HandleTimeout("/fast", time.Second, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+
+	if w := serve(rtr, "GET", "/slow"); w.Code != http.StatusServiceUnavailable || w.Body.String() != "Service Unavailable" {
+		t.Errorf("default timeout response = %d %q", w.Code, w.Body)
+	}
+	if err := <-lateErr; err != http.ErrHandlerTimeout {
+		t.Errorf("write after the timeout returned %v, want ErrHandlerTimeout", err)
+	}
+	rtr.TimeoutResponse(http.StatusGatewayTimeout, []byte(`{"error":"timeout"}`))
+	if w := serve(rtr, "GET", "/slow"); w.Code != http.StatusGatewayTimeout || w.Body.String() != `{"error":"timeout"}` {
+		t.Errorf("custom timeout response = %d %q", w.Code, w.Body)
+	}
+	<-lateErr
+	w := serve(rtr, "GET", "/fast")
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" || w.Header().Get("X-Custom") != "1" {
+		t.Errorf("response in time = %d %q %v", w.Code, w.Body, w.Header())
+	}
+}
@@ -0,0 +1,73 @@
+package yar
+
+import (
+	"errors"
+	"sort"
+)
+
+// Validate checks the whole route table, including the routes of hosts,
+// and returns every problem found joined into one error, or nil. It is
+// meant to be called at startup or in tests once all routes are registered.
+// It reports routes with a nil function, fixed routes that a regexp route
+// also matches (which are only logged when they are registered unless
+// StrictRoutes is set) and methods registered with a nil function. Fixed
+// routes under a HandleSubtree or Mount prefix are not conflicts. Routes
+// aren't named so there are no reverse URLs to check, the only URLs built
+// from a pattern are HandleRedirect targets, which are checked when they
+// are registered.
+func (rtr *Router) Validate() error {
+	errs := rtr.validate("")
+	rtr.mu.RLock()
+	hosts := make([]string, 0, len(rtr.hosts))
+	for h := range rtr.hosts {
+		hosts = append(hosts, h)
+	}
+	subs := rtr.hosts
+	rtr.mu.RUnlock()
+	sort.Strings(hosts)
+	for _, h := range hosts {
+		errs = append(errs, subs[h].validate(h+": ")...)
+	}
+	return errors.Join(errs...)
+}
+
+func (rtr *Router) validate(prefix string) []error {
+	rtr.mu.RLock()
+	defer rtr.mu.RUnlock()
+	errs := []error{}
+	fixed := make([]string, 0, len(rtr.FixedRoutes))
+	for p := range rtr.FixedRoutes {
+		fixed = append(fixed, p)
+	}
+	sort.Strings(fixed)
+	for _, p := range fixed {
+		if rtr.FixedRoutes[p] == nil {
+			errs = append(errs, errors.New(prefix+"Nil function: "+p))
+		}
+		for _, rr := range rtr.Routes {
+			if rtr.shadows(rr) && rr.Pattern.MatchString(p) {
+				errs = append(errs, errors.New(prefix+"Route overlaps: "+p+" and "+rr.Pattern.String()))
+			}
+		}
+	}
+	for _, routes := range []Routes{rtr.guarded, rtr.Routes} {
+		for _, rr := range routes {
+			if rr.Func == nil || (rr.params != nil && rr.params.Func == nil) {
+				errs = append(errs, errors.New(prefix+"Nil function: "+rr.Pattern.String()))
+			}
+		}
+	}
+	keys := make([]string, 0, len(rtr.methods))
+	for k := range rtr.methods {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for m, f := range rtr.methods[k].handlers {
+			if f == nil {
+				errs = append(errs, errors.New(prefix+"Nil function: "+m+" "+k))
+			}
+		}
+	}
+	return errs
+}
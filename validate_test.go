@@ -0,0 +1,63 @@
+package yar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	rtr := NewRouter()
+	h := write("")
+	rtr.HandleFunc("/api/health", h)
+	rtr.Mount("/api", h)
+	rtr.HandleSubtree("/docs", h)
+	rtr.HandleFunc("/docs/intro", h)
+	rtr.HandleMethod("GET", "/items/<id>", h)
+	if err := rtr.Validate(); err != nil {
+		t.Errorf("valid table: %v", err)
+	}
+}
+
+func TestValidateBroken(t *testing.T) {
+	rtr := NewRouter()
+	h := write("")
+	rtr.HandleFunc("/nil", nil)
+	rtr.HandleFunc("/users/new", h)
+	rtr.HandleFunc("/users/<id>", h)
+	rtr.HandleMethod("POST", "/items", nil)
+	rtr.Host("api.example.com").HandleFunc("/v1/<x>", nil)
+	err := rtr.Validate()
+	if err == nil {
+		t.Fatal("broken table validated")
+	}
+	for _, want := range []string{
+		"Nil function: /nil",
+		"Route overlaps: /users/new and /users/([^/]+)",
+		"Nil function: POST /items",
+		"api.example.com: Nil function: /v1/([^/]+)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't report %q", err, want)
+		}
+	}
+}
+
+func TestValidateSubtreeRegexFirst(t *testing.T) {
+	rtr := NewRouter()
+	rtr.RegexFirst = true
+	rtr.Mount("/api", write(""))
+	rtr.HandleFunc("/api/health", write(""))
+	if err := rtr.Validate(); err == nil || !strings.Contains(err.Error(), "/api/health") {
+		t.Errorf("a mount checked first doesn't shadow the routes under it: %v", err)
+	}
+}
+
+func TestHandleRedirectUnknownVariable(t *testing.T) {
+	rtr := NewRouter()
+	if err := rtr.HandleRedirect("/u/<id>", "/users/<uid>", 301); err == nil {
+		t.Error("redirect to an undeclared variable registered")
+	}
+	if err := rtr.HandleRedirect("/u/<id?>", "/users/<id>", 301); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,128 @@
+package yar
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// responseWriter wraps an http.ResponseWriter to record the status code and
+// the time spent since the request reached the router, and to add headers
+// before they are written
+type responseWriter struct {
+	http.ResponseWriter
+	rtr         *Router
+	start       time.Time
+	status      int
+	wroteHeader bool
+	written     int64 // bytes of the body written
+	req         *http.Request
+	writeErr    error // the first error writing the body, e.g. the client went away
+	// set while an ErrorPage is writing the response for pageStatus, and
+	// discard once it has so the rest of the handler's body is dropped
+	pageStatus int
+	discard    bool
+}
+
+func newResponseWriter(rtr *Router, w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, rtr: rtr, start: time.Now()}
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	if rw.pageStatus == 0 {
+		if page := rw.rtr.errorPage(code); page != nil {
+			rw.serveErrorPage(page, code)
+			return
+		}
+	}
+	rw.wroteHeader = true
+	rw.status = code
+	if rw.rtr.DefaultContentType != "" && rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", rw.rtr.DefaultContentType)
+	}
+	if rw.rtr.ServerTiming {
+		rw.setServerTiming()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.discard {
+		return len(b), nil
+	}
+	if !rw.wroteHeader {
+		if rw.pageStatus != 0 {
+			rw.WriteHeader(rw.pageStatus)
+		} else {
+			rw.WriteHeader(http.StatusOK)
+		}
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	if err != nil && rw.writeErr == nil {
+		rw.writeErr = err
+	}
+	return n, err
+}
+
+// Flush passes through to the underlying writer if it supports it
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the original writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// finish is called once the handler has returned
+func (rw *responseWriter) finish() {
+	if !rw.wroteHeader && rw.rtr.ServerTiming {
+		rw.setServerTiming()
+	}
+}
+
+func (rw *responseWriter) setServerTiming() {
+	ms := float64(time.Since(rw.start)) / float64(time.Millisecond)
+	rw.Header().Set("Server-Timing", "total;dur="+strconv.FormatFloat(ms, 'f', 3, 64))
+}
+
+// setRouted records r, the request a route passes on to its handler, as the
+// one an ErrorPage gets. w is the writer the route got, which can be wrapped
+// by middleware.
+func setRouted(w http.ResponseWriter, r *http.Request) {
+	for {
+		switch rw := w.(type) {
+		case *responseWriter:
+			rw.req = r
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// serveErrorPage has page write the response instead of the handler that
+// set the status code, with the request the handler got
+func (rw *responseWriter) serveErrorPage(page http.HandlerFunc, code int) {
+	rw.pageStatus = code
+	// the headers were for the handler's body, not the page
+	rw.Header().Del("Content-Type")
+	rw.Header().Del("Content-Length")
+	rw.Header().Del("X-Content-Type-Options")
+	page(rw, rw.req)
+	if !rw.wroteHeader {
+		rw.WriteHeader(code)
+	}
+	rw.discard = true
+}
@@ -0,0 +1,82 @@
+package yar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerTiming(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/a", write("body"))
+	rtr.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {})
+	if got := serve(rtr, "GET", "/a").Header().Get("Server-Timing"); got != "" {
+		t.Errorf("Server-Timing = %q without ServerTiming set", got)
+	}
+	rtr.ServerTiming = true
+	for _, path := range []string{"/a", "/empty"} {
+		w := serve(rtr, "GET", path)
+		if got := w.Header().Get("Server-Timing"); !strings.HasPrefix(got, "total;dur=") {
+			t.Errorf("GET %s: Server-Timing = %q, want total;dur=...", path, got)
+		}
+	}
+}
+
+func TestDefaultContentType(t *testing.T) {
+	rtr := NewRouter()
+	rtr.HandleFunc("/sniffed", write("<html>"))
+	rtr.HandleFunc("/set", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("<html>"))
+	})
+	rtr.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	if got := serve(rtr, "GET", "/sniffed").Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type without DefaultContentType = %q, want it sniffed", got)
+	}
+	rtr.DefaultContentType = "application/json"
+	tests := map[string]string{
+		"/sniffed": "application/json",
+		"/set":     "text/plain",
+		"/status":  "application/json",
+	}
+	for path, want := range tests {
+		if got := serve(rtr, "GET", path).Header().Get("Content-Type"); got != want {
+			t.Errorf("GET %s: Content-Type %q, want %q", path, got, want)
+		}
+	}
+}
+
+// brokenWriter fails every write of the body like a closed connection
+type brokenWriter struct {
+	http.ResponseWriter
+}
+
+func (brokenWriter) Write([]byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestWriteError(t *testing.T) {
+	rtr := NewRouter()
+	var info RequestInfo
+	var logged lines
+	rtr.Log = true
+	rtr.Logger = &logged
+	rtr.OnRequest = func(i RequestInfo) { info = i }
+	rtr.HandleFunc("/w", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+		w.Write([]byte("b"))
+	})
+	rtr.ServeHTTP(brokenWriter{httptest.NewRecorder()}, httptest.NewRequest("GET", "/w", nil))
+	if info.WriteError == nil || info.WriteError.Error() != "broken pipe" {
+		t.Errorf("OnRequest WriteError = %v, want the first write error", info.WriteError)
+	}
+	if len(logged) == 0 || logged[len(logged)-1] != "write error: /w: broken pipe" {
+		t.Errorf("logged %q, want the write error", logged)
+	}
+	serve(rtr, "GET", "/w")
+	if info.WriteError != nil {
+		t.Errorf("WriteError %v for a response written fine", info.WriteError)
+	}
+}